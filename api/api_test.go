@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"testing"
 
@@ -26,15 +27,14 @@ func TestSerializeMessages(t *testing.T) {
 
 	// Data sources do this:
 
-	cuid, cval, err := source.ProcessRow("user1", "value1")
+	encRow, err := source.ProcessRow("user1", "value1")
 	if err != nil {
 		t.Fatalf("ProcessRow failed: %v", err)
 	}
 
-	bcuid, _ := cuid.Serialize()
+	bcuid, _ := encRow.Cuid.Serialize()
 	fmt.Println("cuid size", len(bcuid))
 
-	encRow := mppj.EncRow{Cuid: cuid, Cval: cval}
 	encRowMsg, err := GetEncRowMsg(encRow)
 	if err != nil {
 		t.Fatalf("GetEncRowMsg failed: %v", err)
@@ -66,3 +66,56 @@ func TestSerializeMessages(t *testing.T) {
 		t.Fatalf("GetEncRowWithHintFromMsg failed: %v", err)
 	}
 }
+
+func TestSerializeSignedRows(t *testing.T) {
+
+	sourceIDs := []mppj.PartyID{"ds1", "ds2"}
+	_, rpk := mppj.KeyGen()
+	opts := make([]mppj.SessionOption, 0, len(sourceIDs))
+	var sk ed25519.PrivateKey
+	for _, sourceID := range sourceIDs {
+		pk, k, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sourceID == sourceIDs[0] {
+			sk = k
+		}
+		opts = append(opts, mppj.WithSourceKey(sourceID, pk))
+	}
+	sess, err := mppj.NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := mppj.NewHelper(sess)
+	source := mppj.NewDataSource(sess, mppj.WithSourceID(sourceIDs[0]), mppj.WithSigningKey(sk))
+
+	encTable, err := source.Prepare(mppj.TablePlain{"user1": "value1", "user2": "value2"})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	chunk, err := source.Sign(encTable)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	msg, err := GetSignedRowsMsg(chunk)
+	if err != nil {
+		t.Fatalf("GetSignedRowsMsg failed: %v", err)
+	}
+
+	fmt.Println("Size of SignedRows message:", proto.Size(msg))
+
+	chunk, err = GetSignedRowsFromMsg(msg)
+	if err != nil {
+		t.Fatalf("GetSignedRowsFromMsg failed: %v", err)
+	}
+	tasks, err := helper.AcceptRows(chunk)
+	if err != nil {
+		t.Fatalf("AcceptRows failed: %v", err)
+	}
+	if len(tasks) != len(encTable) {
+		t.Fatalf("AcceptRows returned %d tasks, expected %d", len(tasks), len(encTable))
+	}
+}
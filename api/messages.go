@@ -5,83 +5,79 @@ import (
 	"github.com/hpicrypto/mppj/api/pb"
 )
 
-const (
-	pointLen = 33
-	ctLen    = 2 * pointLen
-)
-
 func GetEncRowMsg(er mppj.EncRow) (*pb.EncRow, error) {
-	CuidBytes, err := er.Cuid.Serialize()
+	data, err := er.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	CvalBytes, err := er.Cval[0].Serialize()
-	if err != nil {
-		return nil, err
-	}
-	data := make([]byte, 2*ctLen)
-	copy(data[0:ctLen], CuidBytes)
-	copy(data[ctLen:2*ctLen], CvalBytes)
 	return &pb.EncRow{
 		Data: data,
 	}, nil
 }
 
 func GetEncRowFromMsg(msg *pb.EncRow) (mppj.EncRow, error) {
-	cuid, err := mppj.DeserializeCiphertext(msg.Data[:ctLen])
-	if err != nil {
+	var er mppj.EncRow
+	if err := er.UnmarshalBinary(msg.Data); err != nil {
 		return mppj.EncRow{}, err
 	}
-	cval, err := mppj.DeserializeCiphertext(msg.Data[ctLen : 2*ctLen])
-	if err != nil {
-		return mppj.EncRow{}, err
-	}
-	return mppj.EncRow{
-		Cuid: cuid,
-		Cval: []*mppj.Ciphertext{cval},
-	}, nil
+	return er, nil
 }
 
+// GetEncRowWithHintMsg wraps the serialization of a converted row into a message. The serialized row ends with
+// the symmetric ciphertext of the row's values and its authentication tag of mppj.TagSize bytes, which the
+// receiver verifies with the session ID and the row's origin as associated data.
 func GetEncRowWithHintMsg(er mppj.EncRowWithHint) (*pb.EncRowWithHint, error) {
-	cnymBytes, err := er.Cnyme.Serialize()
-	if err != nil {
-		return nil, err
-	}
-	cvalKeyBytes, err := er.CValKey.Serialize()
+	data, err := er.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	chintBytes, err := er.CHint.Serialize()
-	if err != nil {
-		return nil, err
-	}
-	data := make([]byte, 3*ctLen+len(er.CVal))
-	copy(data[0:ctLen], cnymBytes)
-	copy(data[ctLen:2*ctLen], cvalKeyBytes)
-	copy(data[2*ctLen:3*ctLen], chintBytes)
-	copy(data[3*ctLen:], er.CVal)
 	return &pb.EncRowWithHint{
 		Data: data,
 	}, nil
 }
 
+// GetEncRowWithHintFromMsg unwraps a converted row from a message. It returns an error for rows with values
+// that are too short to hold the authentication tag.
 func GetEncRowWithHintFromMsg(msg *pb.EncRowWithHint) (mppj.EncRowWithHint, error) {
-	cnym, err := mppj.DeserializeCiphertext(msg.Data[:ctLen])
-	if err != nil {
+	var er mppj.EncRowWithHint
+	if err := er.UnmarshalBinary(msg.Data); err != nil {
 		return mppj.EncRowWithHint{}, err
 	}
-	cvalKey, err := mppj.DeserializeCiphertext(msg.Data[ctLen : 2*ctLen])
-	if err != nil {
-		return mppj.EncRowWithHint{}, err
+	return er, nil
+}
+
+// GetSignedRowsMsg wraps a signed chunk of encrypted rows into a message, for upload to the helper with
+// PushSignedRows.
+func GetSignedRowsMsg(chunk mppj.SignedRows) (*pb.SignedRows, error) {
+	rows := make([]*pb.EncRow, len(chunk.Rows))
+	for i, row := range chunk.Rows {
+		msg, err := GetEncRowMsg(row)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = msg
 	}
-	chint, err := mppj.DeserializeCiphertext(msg.Data[2*ctLen : 3*ctLen])
-	if err != nil {
-		return mppj.EncRowWithHint{}, err
+	return &pb.SignedRows{
+		Source:    string(chunk.Source),
+		Rows:      rows,
+		Signature: chunk.Signature,
+	}, nil
+}
+
+// GetSignedRowsFromMsg unwraps a signed chunk of encrypted rows from a message. The signature is not verified,
+// see mppj.Helper.AcceptRows.
+func GetSignedRowsFromMsg(msg *pb.SignedRows) (mppj.SignedRows, error) {
+	rows := make(mppj.EncTable, len(msg.Rows))
+	for i, row := range msg.Rows {
+		er, err := GetEncRowFromMsg(row)
+		if err != nil {
+			return mppj.SignedRows{}, err
+		}
+		rows[i] = er
 	}
-	return mppj.EncRowWithHint{
-		Cnyme:   *cnym,
-		CVal:    msg.Data[3*ctLen:],
-		CValKey: *cvalKey,
-		CHint:   *chint,
+	return mppj.SignedRows{
+		Source:    mppj.PartyID(msg.Source),
+		Rows:      rows,
+		Signature: msg.Signature,
 	}, nil
 }
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.35.2
+// 	protoc-gen-go v1.36.8
 // 	protoc        v6.32.1
 // source: mppj.proto
 
@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
 	sync "sync"
+	unsafe "unsafe"
 )
 
 const (
@@ -21,9 +22,9 @@ const (
 )
 
 type Void struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Void) Reset() {
@@ -57,11 +58,10 @@ func (*Void) Descriptor() ([]byte, []int) {
 }
 
 type EncRow struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *EncRow) Reset() {
@@ -102,11 +102,10 @@ func (x *EncRow) GetData() []byte {
 }
 
 type EncRowWithHint struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *EncRowWithHint) Reset() {
@@ -146,55 +145,121 @@ func (x *EncRowWithHint) GetData() []byte {
 	return nil
 }
 
-var File_mppj_proto protoreflect.FileDescriptor
+type SignedRows struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=Source,proto3" json:"Source,omitempty"`
+	Rows          []*EncRow              `protobuf:"bytes,2,rep,name=Rows,proto3" json:"Rows,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=Signature,proto3" json:"Signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-var file_mppj_proto_rawDesc = []byte{
-	0x0a, 0x0a, 0x6d, 0x70, 0x70, 0x6a, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x6d, 0x70,
-	0x70, 0x6a, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x06, 0x0a, 0x04, 0x56, 0x6f, 0x69, 0x64,
-	0x22, 0x1c, 0x0a, 0x06, 0x45, 0x6e, 0x63, 0x52, 0x6f, 0x77, 0x12, 0x12, 0x0a, 0x04, 0x44, 0x61,
-	0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x44, 0x61, 0x74, 0x61, 0x22, 0x24,
-	0x0a, 0x0e, 0x45, 0x6e, 0x63, 0x52, 0x6f, 0x77, 0x57, 0x69, 0x74, 0x68, 0x48, 0x69, 0x6e, 0x74,
-	0x12, 0x12, 0x0a, 0x04, 0x44, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
-	0x44, 0x61, 0x74, 0x61, 0x32, 0x7c, 0x0a, 0x0a, 0x4d, 0x50, 0x50, 0x4a, 0x48, 0x65, 0x6c, 0x70,
-	0x65, 0x72, 0x12, 0x32, 0x0a, 0x08, 0x50, 0x75, 0x73, 0x68, 0x52, 0x6f, 0x77, 0x73, 0x12, 0x12,
-	0x2e, 0x6d, 0x70, 0x70, 0x6a, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x45, 0x6e, 0x63, 0x52,
-	0x6f, 0x77, 0x1a, 0x10, 0x2e, 0x6d, 0x70, 0x70, 0x6a, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
-	0x56, 0x6f, 0x69, 0x64, 0x28, 0x01, 0x12, 0x3a, 0x0a, 0x08, 0x50, 0x75, 0x6c, 0x6c, 0x52, 0x6f,
-	0x77, 0x73, 0x12, 0x10, 0x2e, 0x6d, 0x70, 0x70, 0x6a, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
-	0x56, 0x6f, 0x69, 0x64, 0x1a, 0x1a, 0x2e, 0x6d, 0x70, 0x70, 0x6a, 0x5f, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2e, 0x45, 0x6e, 0x63, 0x52, 0x6f, 0x77, 0x57, 0x69, 0x74, 0x68, 0x48, 0x69, 0x6e, 0x74,
-	0x30, 0x01, 0x42, 0x09, 0x5a, 0x07, 0x6d, 0x70, 0x70, 0x6a, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *SignedRows) Reset() {
+	*x = SignedRows{}
+	mi := &file_mppj_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignedRows) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
+func (*SignedRows) ProtoMessage() {}
+
+func (x *SignedRows) ProtoReflect() protoreflect.Message {
+	mi := &file_mppj_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedRows.ProtoReflect.Descriptor instead.
+func (*SignedRows) Descriptor() ([]byte, []int) {
+	return file_mppj_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SignedRows) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SignedRows) GetRows() []*EncRow {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *SignedRows) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+var File_mppj_proto protoreflect.FileDescriptor
+
+const file_mppj_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"mppj.proto\x12\n" +
+	"mppj_proto\"\x06\n" +
+	"\x04Void\"\x1c\n" +
+	"\x06EncRow\x12\x12\n" +
+	"\x04Data\x18\x01 \x01(\fR\x04Data\"$\n" +
+	"\x0eEncRowWithHint\x12\x12\n" +
+	"\x04Data\x18\x01 \x01(\fR\x04Data\"j\n" +
+	"\n" +
+	"SignedRows\x12\x16\n" +
+	"\x06Source\x18\x01 \x01(\tR\x06Source\x12&\n" +
+	"\x04Rows\x18\x02 \x03(\v2\x12.mppj_proto.EncRowR\x04Rows\x12\x1c\n" +
+	"\tSignature\x18\x03 \x01(\fR\tSignature2\xba\x01\n" +
+	"\n" +
+	"MPPJHelper\x122\n" +
+	"\bPushRows\x12\x12.mppj_proto.EncRow\x1a\x10.mppj_proto.Void(\x01\x12:\n" +
+	"\bPullRows\x12\x10.mppj_proto.Void\x1a\x1a.mppj_proto.EncRowWithHint0\x01\x12<\n" +
+	"\x0ePushSignedRows\x12\x16.mppj_proto.SignedRows\x1a\x10.mppj_proto.Void(\x01B\tZ\amppj/pbb\x06proto3"
+
 var (
 	file_mppj_proto_rawDescOnce sync.Once
-	file_mppj_proto_rawDescData = file_mppj_proto_rawDesc
+	file_mppj_proto_rawDescData []byte
 )
 
 func file_mppj_proto_rawDescGZIP() []byte {
 	file_mppj_proto_rawDescOnce.Do(func() {
-		file_mppj_proto_rawDescData = protoimpl.X.CompressGZIP(file_mppj_proto_rawDescData)
+		file_mppj_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_mppj_proto_rawDesc), len(file_mppj_proto_rawDesc)))
 	})
 	return file_mppj_proto_rawDescData
 }
 
-var file_mppj_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_mppj_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_mppj_proto_goTypes = []any{
 	(*Void)(nil),           // 0: mppj_proto.Void
 	(*EncRow)(nil),         // 1: mppj_proto.EncRow
 	(*EncRowWithHint)(nil), // 2: mppj_proto.EncRowWithHint
+	(*SignedRows)(nil),     // 3: mppj_proto.SignedRows
 }
 var file_mppj_proto_depIdxs = []int32{
-	1, // 0: mppj_proto.MPPJHelper.PushRows:input_type -> mppj_proto.EncRow
-	0, // 1: mppj_proto.MPPJHelper.PullRows:input_type -> mppj_proto.Void
-	0, // 2: mppj_proto.MPPJHelper.PushRows:output_type -> mppj_proto.Void
-	2, // 3: mppj_proto.MPPJHelper.PullRows:output_type -> mppj_proto.EncRowWithHint
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1, // 0: mppj_proto.SignedRows.Rows:type_name -> mppj_proto.EncRow
+	1, // 1: mppj_proto.MPPJHelper.PushRows:input_type -> mppj_proto.EncRow
+	0, // 2: mppj_proto.MPPJHelper.PullRows:input_type -> mppj_proto.Void
+	3, // 3: mppj_proto.MPPJHelper.PushSignedRows:input_type -> mppj_proto.SignedRows
+	0, // 4: mppj_proto.MPPJHelper.PushRows:output_type -> mppj_proto.Void
+	2, // 5: mppj_proto.MPPJHelper.PullRows:output_type -> mppj_proto.EncRowWithHint
+	0, // 6: mppj_proto.MPPJHelper.PushSignedRows:output_type -> mppj_proto.Void
+	4, // [4:7] is the sub-list for method output_type
+	1, // [1:4] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_mppj_proto_init() }
@@ -206,9 +271,9 @@ func file_mppj_proto_init() {
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_mppj_proto_rawDesc,
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_mppj_proto_rawDesc), len(file_mppj_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -217,7 +282,6 @@ func file_mppj_proto_init() {
 		MessageInfos:      file_mppj_proto_msgTypes,
 	}.Build()
 	File_mppj_proto = out.File
-	file_mppj_proto_rawDesc = nil
 	file_mppj_proto_goTypes = nil
 	file_mppj_proto_depIdxs = nil
 }
@@ -19,8 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MPPJHelper_PushRows_FullMethodName = "/mppj_proto.MPPJHelper/PushRows"
-	MPPJHelper_PullRows_FullMethodName = "/mppj_proto.MPPJHelper/PullRows"
+	MPPJHelper_PushRows_FullMethodName       = "/mppj_proto.MPPJHelper/PushRows"
+	MPPJHelper_PullRows_FullMethodName       = "/mppj_proto.MPPJHelper/PullRows"
+	MPPJHelper_PushSignedRows_FullMethodName = "/mppj_proto.MPPJHelper/PushSignedRows"
 )
 
 // MPPJHelperClient is the client API for MPPJHelper service.
@@ -29,6 +30,7 @@ const (
 type MPPJHelperClient interface {
 	PushRows(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[EncRow, Void], error)
 	PullRows(ctx context.Context, in *Void, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EncRowWithHint], error)
+	PushSignedRows(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[SignedRows, Void], error)
 }
 
 type mPPJHelperClient struct {
@@ -71,12 +73,26 @@ func (c *mPPJHelperClient) PullRows(ctx context.Context, in *Void, opts ...grpc.
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type MPPJHelper_PullRowsClient = grpc.ServerStreamingClient[EncRowWithHint]
 
+func (c *mPPJHelperClient) PushSignedRows(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[SignedRows, Void], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MPPJHelper_ServiceDesc.Streams[2], MPPJHelper_PushSignedRows_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SignedRows, Void]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MPPJHelper_PushSignedRowsClient = grpc.ClientStreamingClient[SignedRows, Void]
+
 // MPPJHelperServer is the server API for MPPJHelper service.
 // All implementations must embed UnimplementedMPPJHelperServer
 // for forward compatibility.
 type MPPJHelperServer interface {
 	PushRows(grpc.ClientStreamingServer[EncRow, Void]) error
 	PullRows(*Void, grpc.ServerStreamingServer[EncRowWithHint]) error
+	PushSignedRows(grpc.ClientStreamingServer[SignedRows, Void]) error
 	mustEmbedUnimplementedMPPJHelperServer()
 }
 
@@ -93,6 +109,9 @@ func (UnimplementedMPPJHelperServer) PushRows(grpc.ClientStreamingServer[EncRow,
 func (UnimplementedMPPJHelperServer) PullRows(*Void, grpc.ServerStreamingServer[EncRowWithHint]) error {
 	return status.Errorf(codes.Unimplemented, "method PullRows not implemented")
 }
+func (UnimplementedMPPJHelperServer) PushSignedRows(grpc.ClientStreamingServer[SignedRows, Void]) error {
+	return status.Errorf(codes.Unimplemented, "method PushSignedRows not implemented")
+}
 func (UnimplementedMPPJHelperServer) mustEmbedUnimplementedMPPJHelperServer() {}
 func (UnimplementedMPPJHelperServer) testEmbeddedByValue()                    {}
 
@@ -132,6 +151,13 @@ func _MPPJHelper_PullRows_Handler(srv interface{}, stream grpc.ServerStream) err
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type MPPJHelper_PullRowsServer = grpc.ServerStreamingServer[EncRowWithHint]
 
+func _MPPJHelper_PushSignedRows_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MPPJHelperServer).PushSignedRows(&grpc.GenericServerStream[SignedRows, Void]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MPPJHelper_PushSignedRowsServer = grpc.ClientStreamingServer[SignedRows, Void]
+
 // MPPJHelper_ServiceDesc is the grpc.ServiceDesc for MPPJHelper service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -150,6 +176,11 @@ var MPPJHelper_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _MPPJHelper_PullRows_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "PushSignedRows",
+			Handler:       _MPPJHelper_PushSignedRows_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "mppj.proto",
 }
@@ -1,7 +1,9 @@
 package mppj
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"strconv"
 	"testing"
 )
@@ -22,7 +24,27 @@ func BenchmarkSourceProcessRow(b *testing.B) {
 	source := NewDataSource(sess)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _, err := source.ProcessRow("user1", "value1")
+		_, err := source.ProcessRow("user1", "value1")
+		if err != nil {
+			b.Fatalf("ProcessRow failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSourceProcessRowPrecomputed(b *testing.B) {
+	sourceIDs := []PartyID{"source1", "source2"}
+	_, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	if err != nil {
+		b.Fatalf("Failed to create session: %v", err)
+	}
+	source := NewDataSource(sess)
+	if err := source.Precompute(context.Background(), b.N, 1); err != nil {
+		b.Fatalf("Precompute failed: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := source.ProcessRow("user1", "value1")
 		if err != nil {
 			b.Fatalf("ProcessRow failed: %v", err)
 		}
@@ -39,11 +61,10 @@ func BenchmarkHelperConvertRow(b *testing.B) {
 	source := NewDataSource(sess)
 	helper := NewHelper(sess)
 
-	cuid, cval, err := source.ProcessRow("user1", "value1")
+	encRow, err := source.ProcessRow("user1", "value1")
 	if err != nil {
 		b.Fatalf("ProcessRow failed: %v", err)
 	}
-	encRow := EncRow{Cuid: cuid, Cval: cval}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -56,28 +77,66 @@ func BenchmarkHelperConvertRow(b *testing.B) {
 
 func BenchmarkOps(b *testing.B) {
 	sourceIDs := []PartyID{"source1", "source2"}
-	_, rpk := KeyGen()
+	rsk, rpk := KeyGen()
 	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
 	if err != nil {
 		b.Fatalf("Failed to create session: %v", err)
 	}
 
 	ds := NewDataSource(sess)
-	//helper := NewHelper(sess)
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
 	tables := []TablePlain{make(TablePlain), make(TablePlain)}
 	for i := range 10000 {
 		tables[0][fmt.Sprintf("uid-%d", i)] = fmt.Sprintf("val-%d-1", i)
 		tables[1][fmt.Sprintf("uid-%d", i)] = fmt.Sprintf("val-%d-0", i)
 	}
 	table := tables[0]
+
+	group := make([]EncRowWithHint, len(sourceIDs))
+	for i, sourceID := range sourceIDs {
+		encRow, err := ds.ProcessRow("uid-0", tables[i]["uid-0"])
+		if err != nil {
+			b.Fatalf("ProcessRow failed: %v", err)
+		}
+		row, err := helper.ConvertRow(rpk, &encRow, sourceID)
+		if err != nil {
+			b.Fatalf("ConvertRow failed: %v", err)
+		}
+		group[i] = *row
+	}
+	encRow, err := ds.ProcessRow("uid-1", tables[0]["uid-1"])
+	if err != nil {
+		b.Fatalf("ProcessRow failed: %v", err)
+	}
 	b.ResetTimer()
 
 	b.Run("PrepareStream", func(b *testing.B) {
 		for b.Loop() {
-			ds.PrepareStream(table)
+			ds.PrepareStream(context.Background(), table)
 		}
 	})
 
+	b.Run("ConvertRow", func(b *testing.B) {
+		b.ReportAllocs()
+		var sc convertScratch // reused across rows, as by the workers of ConvertStream
+		task := ConvertRowTask{EncRowMsg: encRow, SourceID: sourceIDs[0]}
+		for b.Loop() {
+			if _, _, err := helper.convertRow(rpk, &task, nil, &sc); err != nil {
+				b.Fatalf("ConvertRow failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("DecryptGroup", func(b *testing.B) {
+		b.ReportAllocs()
+		var sc decryptScratch // reused across groups, as by the workers of JoinTablesStream
+		for b.Loop() {
+			if vals, err := receiver.decryptGroup(group, &sc); err != nil || vals == nil {
+				b.Fatalf("decryptGroup failed: %v", err)
+			}
+		}
+	})
 }
 
 var benchParams = []benchParam{
@@ -142,3 +201,89 @@ func BenchmarkFullJoin(b *testing.B) {
 	}
 
 }
+
+func BenchmarkVerifyProofs(b *testing.B) {
+	_, rpk := KeyGen()
+	sid := []byte("session")
+	key := oprfKeyGen()
+	keyCom := baseExp((*scalar)(key))
+
+	statements := make([]statement, 16)
+	for i := range statements {
+		cnyme := encryptPKE(rpk.bpk, &message{m: *randomPoint()})
+		hint, proof := oprfEvalWithProof(key, keyCom, rpk.bpk, &cnyme, sid)
+		statements[i] = hintStatement{keyComs: []*point{keyCom}, cnyme: &cnyme, hint: &hint, proof: proof}
+	}
+
+	b.Run("Batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if verifyProofs(sid, rpk.bpk, statements) != -1 {
+				b.Fatal("invalid proof")
+			}
+		}
+	})
+	b.Run("Individual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if verifyEach(sid, rpk.bpk, statements) != -1 {
+				b.Fatal("invalid proof")
+			}
+		}
+	})
+}
+
+// BenchmarkStreamScaling measures the scaling of the helper's and receiver's streaming methods in the number
+// of goroutines, up to the number of CPUs.
+func BenchmarkStreamScaling(b *testing.B) {
+	dsNames := []PartyID{"ds1", "ds2"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(dsNames, "helper", "receiver", rpk)
+	if err != nil {
+		b.Fatalf("Failed to create session: %v", err)
+	}
+	ds := NewDataSource(sess)
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	encTables := make(map[PartyID]EncTable)
+	for sourceID, table := range GenTestTables(dsNames, 500, 250) {
+		if encTables[sourceID], err = ds.Prepare(table); err != nil {
+			b.Fatalf("Error in Prepare: %v", err)
+		}
+	}
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		b.Fatalf("Error in Convert: %v", err)
+	}
+
+	goroutines := []int{}
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		goroutines = append(goroutines, n)
+	}
+	goroutines = append(goroutines, runtime.NumCPU())
+
+	for _, n := range goroutines {
+		b.Run(fmt.Sprintf("ConvertStream/%dGoroutines", n), func(b *testing.B) {
+			for b.Loop() {
+				ctx, cancel := context.WithCancel(context.Background())
+				if _, err := helper.ConvertStream(ctx, rpk, helper.feedTables(ctx, encTables), n); err != nil {
+					b.Fatalf("Error in ConvertStream: %v", err)
+				}
+				cancel()
+			}
+		})
+	}
+	for _, n := range goroutines {
+		b.Run(fmt.Sprintf("JoinTablesStream/%dGoroutines", n), func(b *testing.B) {
+			for b.Loop() {
+				in := make(chan EncRowWithHint, len(joinedTables))
+				for _, row := range joinedTables {
+					in <- row
+				}
+				close(in)
+				if _, err := receiver.JoinTablesStream(context.Background(), in, n); err != nil {
+					b.Fatalf("Error in JoinTablesStream: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -4,34 +4,79 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"math/big"
+	"crypto/hmac"
+	"crypto/rand"
 
-	"crypto/elliptic"
-	"crypto/hkdf"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/crypto/blake2b"
 )
 
-var curve = elliptic.P256()
-
 // *********************** Constants ************************
 
 // KeySize is the size of symmetric keys in bytes.
 const KeySize = 16
 
-// MaxValueSize is the maximum size of the input tables' values in bytes.
-const MaxValueSize = 30
+// TagSize is the size in bytes of the authentication tag appended to symmetric ciphertexts.
+const TagSize = 16
+
+const (
+	helperKeyInfo = "ephemeral associated data val key"
+	valueKeyInfo  = "ephemeral hybrid val key"
+)
+
+var zeroNonce = make([]byte, 12) // the standard AES-GCM nonce size
 
-var zeroNonce = make([]byte, aes.BlockSize)
+// ErrAuthentication is returned when a symmetric ciphertext or its associated data were tampered with.
+var ErrAuthentication = errors.New("symmetric ciphertext authentication failed")
 
 // *********************** Types ************************
 
-type publicKey point
+// ciphertextSize is the size in bytes of a serialized Ciphertext.
+var ciphertextSize = 2 * int(group.Params().CompressedElementLength)
+
+// publicKey is an ElGamal public key, along with the fixed-base table built by precompute.
+type publicKey struct {
+	point
+	precomputeOnce sync.Once
+	table          atomic.Pointer[keyTable]
+}
+
+func newPublicKey(p *point) *publicKey {
+	return &publicKey{point: *p}
+}
+
+// precompute builds the fixed-base table of the key, see keyTable. The table is built once, and is shared by
+// the copies of the PublicKey holding pk.
+func (pk *publicKey) precompute() {
+	if pk == nil {
+		return
+	}
+	pk.precomputeOnce.Do(func() {
+		pk.table.Store(newKeyTable(&pk.point))
+	})
+}
+
+// exp exponentiates the key by s, with its fixed-base table if it was precomputed.
+func (pk *publicKey) exp(s *scalar) *point {
+	return pk.expInto(new(point), s)
+}
+
+// expInto is the in-place variant of exp, which sets z = pk^s.
+func (pk *publicKey) expInto(z *point, s *scalar) *point {
+	if table := pk.table.Load(); table != nil {
+		return table.expInto(z, s)
+	}
+	return z.setScalarExp(&pk.point, s)
+}
 
 func (pk *publicKey) String() string {
 	pb, _ := pk.p.MarshalBinary()
@@ -69,6 +114,13 @@ func KeyGen() (SecretKey, PublicKey) {
 	return rsk, rpk
 }
 
+// precompute builds the fixed-base tables of the receiver keys, which speed up the encryptions and
+// re-randomizations of the sources and the helper.
+func (pkt *PublicKey) precompute() {
+	pkt.bpk.precompute()
+	pkt.epk.precompute()
+}
+
 func (pkt *PublicKey) String() string {
 	return fmt.Sprintf("bpk: %v,\nepk: %v", pkt.bpk, pkt.epk)
 }
@@ -80,8 +132,16 @@ type message struct {
 
 // Ciphertext represents an ElGamal ciphertext. c0 = g^r, c1 = m * pk^r
 type Ciphertext struct {
-	c0 *point // g^r
-	c1 *point // m * pk^r
+	c0 point // g^r
+	c1 point // m * pk^r
+}
+
+// EncValue represents an encrypted table value. Values shorter than MaxValueSize bytes are embedded
+// into the group element encrypted by CKey, and Data is empty. Larger values are encrypted in hybrid
+// mode: Data holds the value encrypted under a key derived from a random point, and CKey encrypts that point.
+type EncValue struct {
+	CKey Ciphertext
+	Data SymmetricCiphertext
 }
 
 // pad pads the input byte slice to the next multiple of blockSize.
@@ -116,23 +176,59 @@ func unpad(data []byte) ([]byte, error) {
 // *********************** PKE ************************
 
 // encryptPKE encrypts a message msg using the public key pk.
-func encryptPKE(pk *publicKey, msg *message) *Ciphertext {
+func encryptPKE(pk *publicKey, msg *message) Ciphertext {
+	return encryptPKEWithRandomness(pk, msg, randomScalar())
+}
+
+// encryptPKEWithRandomness encrypts a message msg using the public key pk and the randomness r.
+func encryptPKEWithRandomness(pk *publicKey, msg *message, r *scalar) Ciphertext {
+	var ct Ciphertext
+	ct.setEncrypt(pk, msg, r)
+	return ct
+}
+
+// setEncrypt is the in-place variant of encryptPKEWithRandomness, which sets ct to the encryption of msg.
+func (ct *Ciphertext) setEncrypt(pk *publicKey, msg *message, r *scalar) *Ciphertext {
+	ct.c0.setBaseExp(r)
+	ct.c1.setMul(&msg.m, pk.expInto(&ct.c1, r))
+	return ct
+}
+
+// encrypter encrypts messages under a public key, and returns the encryption randomness along with the
+// ciphertexts. It is implemented by public keys, with fresh randomness, and by the randomness pools.
+type encrypter interface {
+	encrypt(msg *message) (Ciphertext, *scalar)
+}
+
+// encrypt encrypts msg under pk with fresh randomness.
+func (pk *publicKey) encrypt(msg *message) (Ciphertext, *scalar) {
 	r := randomScalar()
+	return encryptPKEWithRandomness(pk, msg, r), r
+}
 
-	c0 := baseExp(r)
-	c1 := mul(&msg.m, (*point)(pk).scalarExp(r))
+// pkePair is precomputed encryption randomness under a public key pk: r along with the pair (g^r, pk^r).
+type pkePair struct {
+	r       *scalar
+	gr, pkr *point
+}
 
-	return &Ciphertext{
-		c0: c0,
-		c1: c1,
-	}
+func newPKEPair(pk *publicKey) pkePair {
+	r := randomScalar()
+	return pkePair{r: r, gr: baseExp(r), pkr: pk.exp(r)}
+}
 
+// encrypt encrypts msg with the randomness of the pair, with a single group operation. Each pair must
+// be used once.
+func (pp pkePair) encrypt(msg *message) Ciphertext {
+	ct := Ciphertext{c0: *pp.gr} // the pair is not used again
+	ct.c1.setMul(&msg.m, pp.pkr)
+	return ct
 }
 
 // encryptVectorPKE encrypts a byte slice PAYLOADSIZE bytes at a time using the public key pk. ( due to the 256-bit curve)
-func encryptVectorPKE(pk *publicKey, msg []byte) ([]*Ciphertext, error) {
+func encryptVectorPKE(pk encrypter, msg []byte) ([]Ciphertext, error) {
 
-	ciphertexts := make([]*Ciphertext, len(pad(msg, MaxValueSize))/MaxValueSize)
+	ciphertexts := make([]Ciphertext, len(pad(msg, MaxValueSize))/MaxValueSize)
 	msg_padded := pad(msg, MaxValueSize)
 
 	for i := 0; i < len(msg_padded); i += MaxValueSize {
@@ -145,7 +241,7 @@ func encryptVectorPKE(pk *publicKey, msg []byte) ([]*Ciphertext, error) {
 		if err != nil {
 			return nil, err
 		}
-		ciphertexts[idx] = encryptPKE(pk, msg)
+		ciphertexts[idx], _ = pk.encrypt(msg)
 	}
 
 	return ciphertexts, nil
@@ -154,22 +250,27 @@ func encryptVectorPKE(pk *publicKey, msg []byte) ([]*Ciphertext, error) {
 
 // decryptPKE decrypts a ciphertext using the secret key sk.
 func decryptPKE(sk *secretKey, ciphertext *Ciphertext) *message {
+	return new(message).setDecrypt(sk, ciphertext)
+}
+
+// setDecrypt is the in-place variant of decryptPKE, which sets msg to the decryption of ciphertext.
+func (msg *message) setDecrypt(sk *secretKey, ciphertext *Ciphertext) *message {
 	// Calculate s = (g ^ r) ^ -sk
-	s := ciphertext.c0.scalarExp((*scalar)(sk))
+	s := msg.m.setScalarExp(&ciphertext.c0, (*scalar)(sk))
 
-	m := mul(ciphertext.c1, s)
+	msg.m.setMul(&ciphertext.c1, s)
 
-	return &message{m: *m}
+	return msg
 }
 
 // decryptVectorPKE decrypts a slice of ciphertexts using the secret key sk.
-func decryptVectorPKE(sk *secretKey, ciphertexts []*Ciphertext) ([]byte, error) {
+func decryptVectorPKE(sk *secretKey, ciphertexts []Ciphertext) ([]byte, error) {
 	msgBytes := make([]byte, 0)
 	msgByteshelper := make([][]byte, len(ciphertexts))
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(ciphertexts))
 
-	for i, ct := range ciphertexts {
+	for i := range ciphertexts {
 		wg.Add(1)
 		go func(i int, ct *Ciphertext) {
 			defer wg.Done()
@@ -179,7 +280,7 @@ func decryptVectorPKE(sk *secretKey, ciphertexts []*Ciphertext) ([]byte, error)
 				return
 			}
 			msgByteshelper[i] = msg
-		}(i, ct)
+		}(i, &ciphertexts[i])
 	}
 	wg.Wait()
 	close(errCh)
@@ -201,44 +302,244 @@ func decryptVectorPKE(sk *secretKey, ciphertexts []*Ciphertext) ([]byte, error)
 }
 
 // reRand re-randomizes a ciphertext using pk.
-func reRand(pk *publicKey, ciphertext *Ciphertext) *Ciphertext {
-	r := randomScalar()
-
-	c0 := mul(ciphertext.c0, baseExp(r))
-	c1 := mul(ciphertext.c1, (*point)(pk).scalarExp(r))
+func reRand(pk *publicKey, ciphertext *Ciphertext) Ciphertext {
+	return reRandWithRandomness(pk, ciphertext, randomScalar())
+}
 
-	return &Ciphertext{
-		c0: c0,
-		c1: c1,
-	}
+// reRandWithRandomness re-randomizes a ciphertext using pk and the randomness r.
+func reRandWithRandomness(pk *publicKey, ciphertext *Ciphertext, r *scalar) Ciphertext {
+	var ct Ciphertext
+	ct.setReRand(pk, ciphertext, r, new(point))
+	return ct
+}
 
+// setReRand is the in-place variant of reRandWithRandomness, which sets ct to the re-randomization of a. The
+// ciphertext a may alias ct, and t is a scratch point of the caller.
+func (ct *Ciphertext) setReRand(pk *publicKey, a *Ciphertext, r *scalar, t *point) *Ciphertext {
+	ct.c0.setMul(&a.c0, t.setBaseExp(r))
+	ct.c1.setMul(&a.c1, pk.expInto(t, r))
+	return ct
 }
 
 // reRandVector re-randomizes a slice of ciphertexts using pk.
-func reRandVector(pk *publicKey, ciphertexts []*Ciphertext) []*Ciphertext {
-	ciphertextsout := make([]*Ciphertext, len(ciphertexts))
+func reRandVector(pk *publicKey, ciphertexts []Ciphertext) []Ciphertext {
+	ciphertextsout := make([]Ciphertext, len(ciphertexts))
 	var wg sync.WaitGroup
-	for i, ct := range ciphertexts {
+	for i := range ciphertexts {
 		wg.Add(1)
-		go func(i int, ct *Ciphertext) {
+		go func(i int) {
 			defer wg.Done()
-			ciphertextsout[i] = reRand(pk, ct)
-		}(i, ct)
+			ciphertextsout[i] = reRand(pk, &ciphertexts[i])
+		}(i)
 	}
 	wg.Wait()
 	return ciphertextsout
 }
 
+// *********************** Values ************************
+
+// encryptValuePKE encrypts a table value using the public key pk. Short values are embedded into a single
+// ciphertext, while larger ones are encrypted under a fresh symmetric key whose point is encrypted under pk.
+func encryptValuePKE(pk encrypter, sid []byte, val []byte) (EncValue, error) {
+	if len(val) < MaxValueSize {
+		cts, err := encryptVectorPKE(pk, val)
+		if err != nil {
+			return EncValue{}, err
+		}
+		return EncValue{CKey: cts[0]}, nil
+	}
+
+	rp, key := randomKeyFromPoint(sid, valueKeyInfo)
+
+	data, err := symmetricEncrypt(key, pad(val, MaxValueSize), sid) // padding hides the value length up to MaxValueSize bytes
+	if err != nil {
+		return EncValue{}, err
+	}
+
+	ckey, _ := pk.encrypt(&message{m: *rp})
+	return EncValue{CKey: ckey, Data: data}, nil
+}
+
+// encryptDummyValuePKE returns an encryption of a dummy value of the given size, which is indistinguishable
+// from the encryption of a real value of the same size without the secret key. The dummy values encrypt the
+// identity element, which is neither a valid embedding nor a valid key point.
+func encryptDummyValuePKE(pk encrypter, size int) (EncValue, error) {
+	ckey, _ := pk.encrypt(&message{m: *identity()})
+	ev := EncValue{CKey: ckey}
+	if size >= MaxValueSize {
+		ev.Data = make(SymmetricCiphertext, len(pad(make([]byte, size), MaxValueSize))+TagSize)
+		if _, err := rand.Read(ev.Data); err != nil {
+			return EncValue{}, err
+		}
+	}
+	return ev, nil
+}
+
+// errDummyValue is returned when decrypting dummy values.
+var errDummyValue = errors.New("dummy value")
+
+// decryptValuePKE decrypts an encrypted table value using the secret key sk.
+func decryptValuePKE(sk *secretKey, sid []byte, ev *EncValue) ([]byte, error) {
+	return decryptValueInto(new(message), new(kdf), sk, sid, ev)
+}
+
+// decryptValueInto is the variant of decryptValuePKE which decrypts the public-key part of the value into the
+// scratch message rp of the caller, and derives the symmetric key with its kdf k.
+func decryptValueInto(rp *message, k *kdf, sk *secretKey, sid []byte, ev *EncValue) ([]byte, error) {
+	rp.setDecrypt(sk, &ev.CKey)
+	if rp.m.p.IsIdentity() {
+		return nil, errDummyValue
+	}
+
+	if len(ev.Data) == 0 {
+		msg, err := rp.GetMessageBytes()
+		if err != nil {
+			return nil, err
+		}
+		return unpad(msg)
+	}
+
+	key, err := k.key(&rp.m, sid, valueKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	padded, err := symmetricDecrypt(key, ev.Data, sid)
+	k.clear()
+	if err != nil {
+		return nil, err
+	}
+	return unpad(padded)
+}
+
+// reRandValue re-randomizes the public-key part of an encrypted value using pk.
+func reRandValue(pk *publicKey, ev *EncValue) EncValue {
+	return EncValue{CKey: reRand(pk, &ev.CKey), Data: ev.Data}
+}
+
+// Serialize serializes an EncValue into a byte slice.
+func (ev *EncValue) Serialize() ([]byte, error) {
+	return ev.appendBinary(nil)
+}
+
+// appendBinary appends the serialization of ev to b.
+func (ev *EncValue) appendBinary(b []byte) ([]byte, error) {
+	b, err := ev.CKey.appendBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, ev.Data...), nil
+}
+
+// DeserializeEncValue deserializes a byte slice into an EncValue.
+func DeserializeEncValue(data []byte) (*EncValue, error) {
+	ev := new(EncValue)
+	if err := ev.deserialize(data); err != nil {
+		return nil, err
+	}
+	if len(ev.Data) > 0 {
+		ev.Data = slices.Clone(ev.Data)
+	}
+	return ev, nil
+}
+
+// deserialize sets ev to the value serialized in data, reusing the elements of ev. The symmetric ciphertext
+// of ev aliases data.
+func (ev *EncValue) deserialize(data []byte) error {
+	if len(data) < ciphertextSize {
+		return errors.New("invalid byte slice length for deserialization of value")
+	}
+	if err := ev.CKey.deserialize(data[:ciphertextSize]); err != nil {
+		return err
+	}
+	ev.Data = nil
+	if len(data) > ciphertextSize {
+		ev.Data = data[ciphertextSize:]
+	}
+	return nil
+}
+
+// serializeEncValues serializes a slice of EncValues into a byte slice, prefixing each value with its length.
+func serializeEncValues(evs []EncValue) ([]byte, error) {
+	return appendEncValues(make([]byte, 0), evs)
+}
+
+// appendEncValues appends the serialization of evs by serializeEncValues to b.
+func appendEncValues(b []byte, evs []EncValue) ([]byte, error) {
+	var err error
+	for i := range evs {
+		n := ciphertextSize + len(evs[i].Data)
+		b = binary.AppendUvarint(b, uint64(n))
+		start := len(b)
+		if b, err = evs[i].appendBinary(b); err != nil {
+			return nil, err
+		}
+		if len(b)-start != n {
+			return nil, errors.New("invalid length of serialized value")
+		}
+	}
+	return b, nil
+}
+
+// deserializeEncValues deserializes a byte slice into a slice of EncValues.
+func deserializeEncValues(data []byte) ([]EncValue, error) {
+	evs, err := deserializeEncValuesInto(nil, data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range evs {
+		if len(evs[i].Data) > 0 {
+			evs[i].Data = slices.Clone(evs[i].Data)
+		}
+	}
+	return evs, nil
+}
+
+// deserializeEncValuesInto deserializes a byte slice into evs, reusing its values and their elements, and
+// returns the resized slice. The symmetric ciphertexts of the values alias data.
+func deserializeEncValuesInto(evs []EncValue, data []byte) ([]EncValue, error) {
+	evs = evs[:0]
+	for len(data) > 0 {
+		evLen, n := binary.Uvarint(data)
+		if n <= 0 || evLen > uint64(len(data)-n) {
+			return nil, errors.New("invalid byte slice length for deserialization of values")
+		}
+		if len(evs) < cap(evs) {
+			evs = evs[:len(evs)+1]
+		} else {
+			evs = append(evs, EncValue{})
+		}
+		if err := evs[len(evs)-1].deserialize(data[n : n+int(evLen)]); err != nil {
+			return nil, err
+		}
+		data = data[n+int(evLen):]
+	}
+	return evs, nil
+}
+
+// Equals checks if two EncValues are equal.
+func (ev *EncValue) Equals(other *EncValue) bool {
+	if ev == nil || other == nil {
+		return ev == other
+	}
+	return ev.CKey.Equals(&other.CKey) && bytes.Equal(ev.Data, other.Data)
+}
+
 // keyGenPKE generates a new public/private key pair. (scalar, point)
 func keyGenPKE() (*secretKey, *publicKey) {
 	sk := randomScalar()
 
 	pk := baseExp(sk)
-	return (*secretKey)(sk.neg()), (*publicKey)(pk) // Negate the scalar for efficiency
+	return (*secretKey)(sk.neg()), newPublicKey(pk) // Negate the scalar for efficiency
 }
 
 // Serialize serializes a Ciphertext into a byte slice.
 func (ct *Ciphertext) Serialize() ([]byte, error) {
+	return ct.appendBinary(make([]byte, 0, ciphertextSize))
+}
+
+// appendBinary appends the serialization of ct to b.
+func (ct *Ciphertext) appendBinary(b []byte) ([]byte, error) {
 	c0Bytes, err := ct.c0.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -248,68 +549,54 @@ func (ct *Ciphertext) Serialize() ([]byte, error) {
 		return nil, err
 	}
 
-	return append(c0Bytes, c1Bytes...), nil
+	return append(append(b, c0Bytes...), c1Bytes...), nil
 }
 
-func serializeCiphertexts(cts []*Ciphertext) ([]byte, error) {
-	serialized := make([]byte, 0)
-	for _, ct := range cts {
-		serializedct, err := ct.Serialize()
-		if err != nil {
+func serializeCiphertexts(cts []Ciphertext) ([]byte, error) {
+	serialized := make([]byte, 0, len(cts)*ciphertextSize)
+	var err error
+	for i := range cts {
+		if serialized, err = cts[i].appendBinary(serialized); err != nil {
 			return nil, err
 		}
-
-		serialized = append(serialized, serializedct...)
 	}
 	return serialized, nil
 }
 
 // deserializeCiphertexts deserializes a byte slice into a slice of Ciphertexts.
-func deserializeCiphertexts(data []byte) ([]*Ciphertext, error) {
+func deserializeCiphertexts(data []byte) ([]Ciphertext, error) {
 
-	byteLen := int(group.Params().CompressedElementLength)
-	ciphertextlen := 2 * byteLen
-	if len(data)%(ciphertextlen) != 0 {
+	if len(data)%ciphertextSize != 0 {
 		return nil, errors.New("invalid byte slice length for deserialization of array")
 	}
-	ciphertexts := make([]*Ciphertext, 0)
-	for i := 0; i < len(data); i += ciphertextlen {
-		ciphertext, err := DeserializeCiphertext(data[i : i+ciphertextlen])
-		if err != nil {
+	ciphertexts := make([]Ciphertext, len(data)/ciphertextSize)
+	for i := range ciphertexts {
+		if err := ciphertexts[i].deserialize(data[i*ciphertextSize : (i+1)*ciphertextSize]); err != nil {
 			return nil, err
 		}
-
-		ciphertexts = append(ciphertexts, ciphertext)
 	}
 	return ciphertexts, nil
 }
 
 // DeserializeCiphertext deserializes a byte slice into a Ciphertext.
 func DeserializeCiphertext(data []byte) (*Ciphertext, error) {
-	byteLen := int(group.Params().CompressedElementLength)
-	pointLen := 2 * byteLen
-
-	if len(data) != pointLen {
-		return nil, errors.New("invalid byte slice length for deserialization")
-	}
-
-	c0 := newPoint()
-	c1 := newPoint()
-
-	err := c0.UnmarshalBinary(data[:byteLen])
-	if err != nil {
+	ct := new(Ciphertext)
+	if err := ct.deserialize(data); err != nil {
 		return nil, err
 	}
+	return ct, nil
+}
 
-	err = c1.UnmarshalBinary(data[byteLen:])
-	if err != nil {
-		return nil, err
+// deserialize sets ct to the ciphertext serialized in data, reusing the elements of ct.
+func (ct *Ciphertext) deserialize(data []byte) error {
+	if len(data) != ciphertextSize {
+		return errors.New("invalid byte slice length for deserialization")
 	}
 
-	return &Ciphertext{
-		c0: c0,
-		c1: c1,
-	}, nil
+	if err := ct.c0.elem().UnmarshalBinary(data[:ciphertextSize/2]); err != nil {
+		return err
+	}
+	return ct.c1.elem().UnmarshalBinary(data[ciphertextSize/2:])
 }
 
 func (msg *message) String() string {
@@ -332,83 +619,27 @@ func (ct *Ciphertext) Equals(other *Ciphertext) bool {
 	if ct == nil || other == nil {
 		return ct == other
 	}
-	return ct.c0.Equals(other.c0) && ct.c1.Equals(other.c1)
+	return ct.c0.Equals(&other.c0) && ct.c1.Equals(&other.c1)
 }
 
+// newMessageFromBytes embeds a non-empty byte slice of at most MaxValueSize bytes into a message point, with
+// the embedding of the compiled suite.
 func newMessageFromBytes(msgBytesin []byte) (*message, error) {
-	params := curve.Params()
-
 	if len(msgBytesin) == 0 {
 		return nil, errors.New("Empty message unsupported")
 	}
 
-	msgBytes := make([]byte, len(msgBytesin))
-
-	copy(msgBytes, msgBytesin)
-
-	// Prefix msgInt with one LSB byte
-	msgBytes = append(msgBytes, 0x02)            // Prefix LSB byte
-	msgBytes = append([]byte{0x04}, msgBytes...) // Postfix MSB byte
-	msgInt := new(big.Int).SetBytes(msgBytes)
-
-	i := 1
-
-	y := new(big.Int)
-	for {
-		// adapted from elliptic.polynomial
-		x3 := new(big.Int).Mul(msgInt, msgInt)
-		x3.Mul(x3, msgInt)
-
-		threeX := new(big.Int).Lsh(msgInt, 1)
-		threeX.Add(threeX, msgInt)
-
-		x3.Sub(x3, threeX)
-		x3.Add(x3, params.B)
-		x3.Mod(x3, params.P)
-
-		// Try to calculate the square root mod p (y = sqrt(y^2) mod p)
-		y = new(big.Int).ModSqrt(x3, params.P)
-		if y != nil {
-			break
-		}
-
-		if i == 255 { // there is only one byte of space for the counter
-			return nil, errors.New("Failed to find a valid message point")
-
-		}
-		i++
-
-		// Update msgInt for the next iteration if not valid
-		msgInt.Add(msgInt, big.NewInt(1))
-	}
-
-	pointBytes := elliptic.Marshal(curve, msgInt, y)
-	result := newPoint()
-	err := result.UnmarshalBinary(pointBytes)
+	m, err := embed(msgBytesin)
 	if err != nil {
-		return nil, err // when using a compatible curve, this should never happen
+		return nil, err
 	}
 
-	return &message{m: *result}, nil
+	return &message{m: *m}, nil
 }
 
 // GetMessageBytes returns the message as a byte slice.
 func (msg *message) GetMessageBytes() ([]byte, error) {
-	serialized, err := msg.m.MarshalBinary()
-	if err != nil {
-		return nil, err
-	}
-	x, _ := elliptic.UnmarshalCompressed(curve, serialized)
-
-	if x == nil {
-		return nil, fmt.Errorf("failed to unmarshal message point")
-	}
-
-	msgBytes := x.Bytes()
-
-	msgBytes = msgBytes[1 : len(msgBytes)-1] // Remove the prefix and LSB
-
-	return msgBytes, nil
+	return extract(&msg.m)
 }
 
 func (msg *message) GetMessageString() (string, error) {
@@ -444,10 +675,10 @@ func hashToMessage(msg, sid []byte) *message {
 // *********************** Symmetric ************************
 
 // randomKeyFromPoint generates a random 16-byte key from a random point on the curve
-func randomKeyFromPoint(sid []byte) (*point, []byte) {
+func randomKeyFromPoint(sid []byte, info string) (*point, []byte) {
 	rp := randomPoint()
 
-	key, err := keyFromPoint(rp, sid)
+	key, err := keyFromPoint(rp, sid, info)
 	if err != nil {
 		panic(err) // Random points are assumed to be "correct"
 	}
@@ -455,45 +686,87 @@ func randomKeyFromPoint(sid []byte) (*point, []byte) {
 	return rp, key
 }
 
-func keyFromPoint(rp *point, sid []byte) ([]byte, error) {
-	info := "ephemeral associated data val key"
+// keyFromPoint derives a symmetric key from a point, for the given session and purpose.
+func keyFromPoint(rp *point, sid []byte, info string) ([]byte, error) {
+	return new(kdf).key(rp, sid, info)
+}
 
-	serialized, err := rp.MarshalBinary()
+// kdf computes the HKDF-SHA256 of keyFromPoint with the HMAC of the extraction, keyed by the session ID, and the
+// buffers reused across calls, for the workers which derive keys for every row. The key it returns is overwritten
+// by the next call, and should be cleared with clear after use. The zero value is ready to use, and a kdf is not
+// safe for concurrent use.
+type kdf struct {
+	salt    []byte
+	extract hash.Hash
+	prk     [sha256.Size]byte
+	out     [sha256.Size]byte
+	info    []byte
+}
+
+// key derives a symmetric key from a point as keyFromPoint.
+func (k *kdf) key(rp *point, sid []byte, info string) ([]byte, error) {
+	ikm, err := rp.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
+	defer clear(ikm)
 
-	key, err := hkdf.Key(sha256.New, serialized, sid, info, KeySize)
-	if err != nil {
-		return nil, err
+	if k.extract == nil || !bytes.Equal(k.salt, sid) {
+		k.salt = append(k.salt[:0], sid...)
+		k.extract = hmac.New(sha256.New, k.salt)
 	}
+	k.extract.Reset()
+	k.extract.Write(ikm) // HKDF-Extract, with the session ID as salt
+	prk := k.extract.Sum(k.prk[:0])
+	defer clear(k.prk[:])
+
+	expand := hmac.New(sha256.New, prk)
+	k.info = append(append(k.info[:0], info...), 1)
+	expand.Write(k.info)
+	return expand.Sum(k.out[:0])[:KeySize], nil // HKDF-Expand, of which a single block is needed
+}
 
-	return key, nil
+// clear zeroes the last key derived by k.
+func (k *kdf) clear() {
+	clear(k.out[:])
 }
 
-// ctr encrypts/decrypts the plaintext using AES-CTR with the given key and nonce.
-func ctr(key, plaintext []byte) (SymmetricCiphertext, error) {
+// gcm returns the AES-GCM AEAD for the given key.
+func gcm(key []byte) (cipher.AEAD, error) {
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
-	ciphertext := make([]byte, len(plaintext))
-	cipher.NewCTR(block, zeroNonce).XORKeyStream(ciphertext, plaintext) // nonce = 16 * 0x00 since each key is used only once
-
-	return ciphertext, nil
+	return cipher.NewGCMWithTagSize(block, TagSize)
+}
 
+// Encrypt the plaintext bytes with the symmetric key using AES-GCM, authenticating the associated data ad
+func symmetricEncrypt(key, plaintext, ad []byte) (SymmetricCiphertext, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, zeroNonce, plaintext, ad), nil // nonce = 12 * 0x00 since each key is used only once
 }
 
-// Encrypt the plaintext bytes with the symmetric key using AES-CTR
-func symmetricEncrypt(key []byte, plaintext []byte) (SymmetricCiphertext, error) {
-	return ctr(key, plaintext)
+// Decrypt the ciphertext bytes with the symmetric key using AES-GCM, authenticating the associated data ad
+func symmetricDecrypt(key []byte, ciphertext SymmetricCiphertext, ad []byte) ([]byte, error) {
+	return appendSymmetricDecrypt(nil, key, ciphertext, ad)
 }
 
-// Decrypt the ciphertext bytes with the symmetric key using AES-CTR
-func symmetricDecrypt(key []byte, ciphertext SymmetricCiphertext) ([]byte, error) {
-	return ctr(key, ciphertext)
+// appendSymmetricDecrypt is the variant of symmetricDecrypt which appends the plaintext to dst.
+func appendSymmetricDecrypt(dst, key []byte, ciphertext SymmetricCiphertext, ad []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(dst, zeroNonce, ciphertext, ad)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return plaintext, nil
 }
 
 // Generates keys *deterministically* from a seed
@@ -507,7 +780,7 @@ func GetTestKeys(seed []byte) (SecretKey, PublicKey) {
 	esk := &scalar{s: group.RandomScalar(xof)}
 	bsk := &scalar{s: group.RandomScalar(xof)}
 	rsk := SecretKey{esk: (*secretKey)(esk.neg()), bsk: (*secretKey)(bsk.neg())}
-	rpk := PublicKey{epk: (*publicKey)(baseExp(esk)), bpk: (*publicKey)(baseExp(bsk))}
+	rpk := PublicKey{epk: newPublicKey(baseExp(esk)), bpk: newPublicKey(baseExp(bsk))}
 
 	return rsk, rpk
 }
@@ -2,9 +2,12 @@ package mppj
 
 import (
 	"bytes"
+	"crypto/hkdf"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -19,7 +22,7 @@ func TestEncryptDecrypt(t *testing.T) {
 
 	plaintext := []byte("This is a secret message")
 
-	ciphertext, err := symmetricEncrypt(key, plaintext)
+	ciphertext, err := symmetricEncrypt(key, plaintext, nil)
 	if err != nil {
 		t.Errorf("encrypt() error = %v", err)
 	}
@@ -27,7 +30,7 @@ func TestEncryptDecrypt(t *testing.T) {
 		t.Fatalf("encrypt() returned empty ciphertext")
 	}
 
-	decrypted, err := symmetricDecrypt(key, ciphertext)
+	decrypted, err := symmetricDecrypt(key, ciphertext, nil)
 	if err != nil {
 		t.Errorf("decrypt() error = %v", err)
 	}
@@ -44,12 +47,12 @@ func TestEncryptDecrypt_EmptyPlaintext(t *testing.T) {
 
 	plaintext := []byte("")
 
-	ciphertext, err := symmetricEncrypt(key, plaintext)
+	ciphertext, err := symmetricEncrypt(key, plaintext, nil)
 	if err != nil {
 		t.Errorf("encrypt() error = %v", err)
 	}
 
-	decrypted, err := symmetricDecrypt(key, ciphertext)
+	decrypted, err := symmetricDecrypt(key, ciphertext, nil)
 	if err != nil {
 		t.Errorf("decrypt() error = %v", err)
 	}
@@ -70,13 +73,53 @@ func TestSymmetricEncryptDecrypt(t *testing.T) {
 
 	plaintext := []byte("This is a secret message")
 
-	ciphertext, err := symmetricEncrypt(key, plaintext)
+	ciphertext, err := symmetricEncrypt(key, plaintext, nil)
 	require.NoError(t, err, "symmetricEncrypt() error")
 	require.NotEmpty(t, ciphertext, "symmetricEncrypt() returned empty ciphertext")
 
-	decryptedText, err := symmetricDecrypt(key, ciphertext)
+	decryptedText, err := symmetricDecrypt(key, ciphertext, nil)
 	require.NoError(t, err, "symmetricDecrypt() error")
 	require.Equal(t, plaintext, decryptedText, "symmetricDecrypt() did not return the original plaintext")
+	require.Len(t, ciphertext, len(plaintext)+TagSize, "unexpected ciphertext size")
+
+	_, err = symmetricDecrypt(invalidKey, ciphertext, nil)
+	require.ErrorIs(t, err, ErrAuthentication, "symmetricDecrypt() with an invalid key")
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[0] ^= 1
+	_, err = symmetricDecrypt(key, tampered, nil)
+	require.ErrorIs(t, err, ErrAuthentication, "symmetricDecrypt() of a tampered ciphertext")
+
+	ad := []byte("associated data")
+	ciphertext, err = symmetricEncrypt(key, plaintext, ad)
+	require.NoError(t, err, "symmetricEncrypt() error")
+	_, err = symmetricDecrypt(key, ciphertext, []byte("other data"))
+	require.ErrorIs(t, err, ErrAuthentication, "symmetricDecrypt() with other associated data")
+}
+
+func TestKeyFromPoint(t *testing.T) {
+	var k kdf
+	for _, sidLen := range []int{0, 32, sha256.BlockSize, 100} {
+		sid := make([]byte, sidLen)
+		_, err := io.ReadFull(rand.Reader, sid)
+		require.NoError(t, err, "Failed to generate session ID")
+
+		for range 3 { // the kdf is reused
+			rp := randomPoint()
+			serialized, err := rp.MarshalBinary()
+			require.NoError(t, err)
+			expected, err := hkdf.Key(sha256.New, serialized, sid, valueKeyInfo, KeySize)
+			require.NoError(t, err)
+
+			key, err := k.key(rp, sid, valueKeyInfo)
+			require.NoError(t, err, "key() error")
+			require.Equal(t, expected, key, "key() differs from HKDF-SHA256 for a session ID of %d bytes", sidLen)
+
+			k.clear()
+			require.Equal(t, make([]byte, KeySize), key, "clear() left the key")
+			require.Equal(t, [sha256.Size]byte{}, k.prk, "key() left the pseudorandom key")
+		}
+	}
 }
 
 func TestDecrypt(t *testing.T) {
@@ -92,7 +135,7 @@ func TestDecrypt(t *testing.T) {
 	sk, pk := keyGenPKE()
 
 	ciphertext := encryptPKE(pk, msg)
-	decryptedMsg := decryptPKE(sk, ciphertext)
+	decryptedMsg := decryptPKE(sk, &ciphertext)
 	if decryptedMsg == nil {
 		t.Fatalf("Decrypt() returned nil message")
 	}
@@ -120,12 +163,12 @@ func TestReRand(t *testing.T) {
 	_, pk := keyGenPKE()
 
 	ciphertext := encryptPKE(pk, msg)
-	rerandCiphertext := reRand(pk, ciphertext)
-	if rerandCiphertext == nil {
+	rerandCiphertext := reRand(pk, &ciphertext)
+	if rerandCiphertext.c0.p == nil || rerandCiphertext.c1.p == nil {
 		t.Fatalf("ReRand() returned nil ciphertext")
 	}
 
-	if rerandCiphertext.c0 == nil || rerandCiphertext.c1 == nil {
+	if rerandCiphertext.c0.p == nil || rerandCiphertext.c1.p == nil {
 		t.Fatalf("ReRand() returned ciphertext with nil components")
 	}
 }
@@ -144,18 +187,18 @@ func TestIntegration(t *testing.T) {
 
 	// Encrypt
 	ciphertext := encryptPKE(pk, msg)
-	if ciphertext == nil {
+	if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 		t.Fatalf("Encrypt() returned nil ciphertext")
 	}
 
 	// Re-randomize
-	rerandCiphertext := reRand(pk, ciphertext)
-	if rerandCiphertext == nil {
+	rerandCiphertext := reRand(pk, &ciphertext)
+	if rerandCiphertext.c0.p == nil || rerandCiphertext.c1.p == nil {
 		t.Fatalf("ReRand() returned nil ciphertext")
 	}
 
 	// Decrypt
-	decryptedMsg := decryptPKE(sk, rerandCiphertext)
+	decryptedMsg := decryptPKE(sk, &rerandCiphertext)
 	if decryptedMsg == nil {
 		t.Fatalf("Decrypt() returned nil message")
 	}
@@ -181,18 +224,18 @@ func TestPlaintext(t *testing.T) {
 
 	// Encrypt
 	ciphertext := encryptPKE(pk, msg)
-	if ciphertext == nil {
+	if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 		t.Fatalf("Encrypt() returned nil ciphertext")
 	}
 
 	// Re-randomize
-	rerandCiphertext := reRand(pk, ciphertext)
-	if rerandCiphertext == nil {
+	rerandCiphertext := reRand(pk, &ciphertext)
+	if rerandCiphertext.c0.p == nil || rerandCiphertext.c1.p == nil {
 		t.Fatalf("ReRand() returned nil ciphertext")
 	}
 
 	// Decrypt
-	decryptedMsg := decryptPKE(sk, rerandCiphertext)
+	decryptedMsg := decryptPKE(sk, &rerandCiphertext)
 	if decryptedMsg == nil {
 		t.Fatalf("Decrypt() returned nil message")
 	}
@@ -212,7 +255,7 @@ func TestPlaintextUUID(t *testing.T) {
 		if i == 0 {
 			continue
 		}
-		msg_str := uuid.New().String()[:i]
+		msg_str := strings.Repeat(uuid.New().String(), 2)[:i] // longer than the embedding of every suite
 		msg, err := newMessageFromBytes([]byte(msg_str))
 		if err != nil {
 			t.Fatalf("Failed to create message: %v", err)
@@ -221,18 +264,18 @@ func TestPlaintextUUID(t *testing.T) {
 
 		// Encrypt
 		ciphertext := encryptPKE(pk, msg)
-		if ciphertext == nil {
+		if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 			t.Fatalf("Encrypt() returned nil ciphertext")
 		}
 
 		// Re-randomize
-		rerandCiphertext := reRand(pk, ciphertext)
-		if rerandCiphertext == nil {
+		rerandCiphertext := reRand(pk, &ciphertext)
+		if rerandCiphertext.c0.p == nil || rerandCiphertext.c1.p == nil {
 			t.Fatalf("ReRand() returned nil ciphertext")
 		}
 
 		// Decrypt
-		decryptedMsg := decryptPKE(sk, rerandCiphertext)
+		decryptedMsg := decryptPKE(sk, &rerandCiphertext)
 		if decryptedMsg == nil {
 			t.Fatalf("Decrypt() returned nil message")
 		}
@@ -270,7 +313,7 @@ func TestSerializeDeserializeCiphertexts(t *testing.T) {
 
 	// Encrypt
 	ciphertext := encryptPKE(pk, msg)
-	if ciphertext == nil {
+	if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 		t.Fatalf("Encrypt() returned nil ciphertext")
 	}
 
@@ -290,7 +333,7 @@ func TestSerializeDeserializeCiphertexts(t *testing.T) {
 	}
 
 	// Check if the deserialized ciphertext matches the original ciphertext
-	if !deserializedCiphertext.Equals(ciphertext) {
+	if !deserializedCiphertext.Equals(&ciphertext) {
 		t.Errorf("Deserialize() = %v, want %v", deserializedCiphertext, ciphertext)
 	}
 }
@@ -364,7 +407,7 @@ func TestSerializeDeserializeCiphertextsVector(t *testing.T) {
 
 	// Check if the deserialized ciphertext matches the original ciphertext
 	for i := range ciphertexts {
-		if !deserializedCiphertext[i].Equals(ciphertexts[i]) {
+		if !deserializedCiphertext[i].Equals(&ciphertexts[i]) {
 			t.Errorf("Deserialize() = %v, want %v", deserializedCiphertext[i], ciphertexts[i])
 		}
 	}
@@ -435,14 +478,14 @@ func TestPKEEncKeys(t *testing.T) {
 
 		// Encrypt
 		ciphertext := encryptPKE(pk, msg)
-		if ciphertext == nil {
+		if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 			t.Fatalf("Encrypt() returned nil ciphertext")
 		}
 
-		ciphertext = reRand(pk, ciphertext)
+		ciphertext = reRand(pk, &ciphertext)
 
 		// Decrypt
-		decryptedMsg := decryptPKE(sk, ciphertext)
+		decryptedMsg := decryptPKE(sk, &ciphertext)
 		if decryptedMsg == nil {
 			t.Fatalf("Decrypt() returned nil message")
 		}
@@ -458,3 +501,29 @@ func TestPKEEncKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryptValue(t *testing.T) {
+	sid := []byte("test session")
+	sk, pk := keyGenPKE()
+
+	for _, size := range []int{1, MaxValueSize - 1, MaxValueSize, 100, 1024} {
+		msgBytes := make([]byte, size)
+		_, err := rand.Read(msgBytes)
+		require.NoError(t, err, "Failed to generate random bytes")
+
+		encVal, err := encryptValuePKE(pk, sid, msgBytes)
+		require.NoError(t, err, "encryptValuePKE() error")
+		require.Equal(t, size >= MaxValueSize, len(encVal.Data) > 0, "unexpected encryption mode for size %d", size)
+
+		rerandVal := reRandValue(pk, &encVal)
+		serialized, err := rerandVal.Serialize()
+		require.NoError(t, err, "Serialize() error")
+
+		deserialized, err := DeserializeEncValue(serialized)
+		require.NoError(t, err, "DeserializeEncValue() error")
+
+		plaintext, err := decryptValuePKE(sk, sid, deserialized)
+		require.NoError(t, err, "decryptValuePKE() error")
+		require.Equal(t, msgBytes, plaintext, "decryptValuePKE() did not return the original value for size %d", size)
+	}
+}
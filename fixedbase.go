@@ -0,0 +1,66 @@
+package mppj
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"sync"
+
+	circl "github.com/cloudflare/circl/group"
+)
+
+// fixedBaseWindow is the width in bits of the scalar digits of the fixed-base tables.
+const fixedBaseWindow = 4
+
+// fixedBase is a table of multiples of a fixed point, with table[i][j] = base^(j * 2^(fixedBaseWindow*i)).
+// An exponentiation adds one entry of each row, and does not double.
+type fixedBase struct {
+	table [][1 << fixedBaseWindow]circl.Element
+}
+
+// scalarLittleEndian returns whether the scalars of the suite are encoded in little-endian order, as for
+// ristretto255 and edwards25519, or in big-endian order, as for the NIST curves. It is evaluated lazily, as
+// the groups may not be initialized before the variables of this file.
+var scalarLittleEndian = sync.OnceValue(func() bool {
+	one, _ := newScalar(big.NewInt(1)).s.MarshalBinary()
+	return one[0] == 1
+})
+
+// newFixedBase builds the table of the multiples of base.
+func newFixedBase(base *point) *fixedBase {
+	rows := 8 * group.Params().ScalarLength / fixedBaseWindow
+	fb := &fixedBase{table: make([][1 << fixedBaseWindow]circl.Element, rows)}
+
+	b := base.p.Copy()
+	for i := range fb.table {
+		row := &fb.table[i]
+		row[0] = group.Identity()
+		for j := 1; j < len(row); j++ {
+			row[j] = group.NewElement().Add(row[j-1], b)
+		}
+		b = group.NewElement().Add(row[len(row)-1], b)
+	}
+	return fb
+}
+
+// expInto sets z to the base exponentiated by s, and returns z. The entries are selected with conditional
+// moves, which are constant time for the circl groups.
+func (fb *fixedBase) expInto(z *point, s *scalar) *point {
+	sb, _ := s.s.MarshalBinary()
+
+	acc := z.elem().Set(fb.table[0][0]) // the identity
+	sel := group.NewElement()
+	for i, row := range fb.table {
+		k := i * fixedBaseWindow / 8
+		if !scalarLittleEndian() {
+			k = len(sb) - 1 - k
+		}
+		digit := sb[k] >> (i * fixedBaseWindow % 8) & (1<<fixedBaseWindow - 1)
+
+		sel.Set(row[0])
+		for j := 1; j < len(row); j++ {
+			sel.CMov(subtle.ConstantTimeByteEq(digit, uint8(j)), row[j])
+		}
+		acc.Add(acc, sel)
+	}
+	return z
+}
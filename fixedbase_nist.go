@@ -0,0 +1,117 @@
+//go:build !mppj_ristretto255 && !mppj_edwards25519
+
+package mppj
+
+import "crypto/subtle"
+
+// nistPoint is the interface of the points of filippo.io/nistec, which are added in projective coordinates
+// with complete, constant-time formulas. The circl groups of the NIST curves add them through the affine
+// big.Int API of crypto/elliptic instead, for which a table would be slower than an exponentiation.
+type nistPoint[P any] interface {
+	*P
+	Set(q *P) *P
+	Add(p1, p2 *P) *P
+	Double(q *P) *P
+	IsInfinity() int
+	Select(p1, p2 *P, cond int) *P
+	SetBytes(b []byte) (*P, error)
+	Bytes() []byte
+}
+
+// nistFixedBase is the table of fixedBase for the NIST curves, with the points of nistec.
+type nistFixedBase[P any, PP nistPoint[P]] struct {
+	table    [][1 << fixedBaseWindow]P
+	newPoint func() PP
+}
+
+// newNISTFixedBase builds the table of the multiples of base, with newPoint the constructor of the points of
+// the curve, as their zero values are not usable.
+func newNISTFixedBase[P any, PP nistPoint[P]](base *point, newPoint func() PP) *nistFixedBase[P, PP] {
+	rows := 8 * group.Params().ScalarLength / fixedBaseWindow
+	fb := &nistFixedBase[P, PP]{table: make([][1 << fixedBaseWindow]P, rows), newPoint: newPoint}
+
+	bb, err := base.p.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	b := newPoint()
+	if _, err := b.SetBytes(bb); err != nil {
+		panic(err)
+	}
+	for i := range fb.table {
+		row := &fb.table[i]
+		row[0] = *newPoint() // the identity
+		for j := 1; j < len(row); j++ {
+			row[j] = *newPoint().Add(&row[j-1], b)
+		}
+		b.Add(&row[len(row)-1], b)
+	}
+	return fb
+}
+
+// expInto sets z to the base exponentiated by s, and returns z, as fixedBase.expInto. The scalars of the NIST
+// curves are encoded in big-endian order.
+func (fb *nistFixedBase[P, PP]) expInto(z *point, s *scalar) *point {
+	sb, _ := s.s.MarshalBinary()
+
+	acc, sel := fb.newPoint(), fb.newPoint() // the identity
+	for i := range fb.table {
+		row := &fb.table[i]
+		k := len(sb) - 1 - i*fixedBaseWindow/8
+		digit := sb[k] >> (i * fixedBaseWindow % 8) & (1<<fixedBaseWindow - 1)
+
+		sel.Set(&row[0])
+		for j := 1; j < len(row); j++ {
+			sel.Select(&row[j], sel, subtle.ConstantTimeByteEq(digit, uint8(j)))
+		}
+		acc.Add(acc, sel)
+	}
+	if err := z.elem().UnmarshalBinary(acc.Bytes()); err != nil {
+		panic(err)
+	}
+	return z
+}
+
+// nistMultiExp returns the product of the bases raised to the exponents, as circlMultiExp, with the points of
+// nistec, whose constructor is newPoint.
+func nistMultiExp[P any, PP nistPoint[P]](bases []*point, exps []*scalar, newPoint func() PP) *point {
+	tables := make([][1<<multiExpWindow - 1]P, len(bases))
+	digits := make([][]byte, len(bases))
+	for i, base := range bases {
+		bb, err := base.p.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		b := newPoint()
+		if _, err := b.SetBytes(bb); err != nil {
+			panic(err)
+		}
+		tables[i][0] = *b
+		for j := 1; j < len(tables[i]); j++ {
+			tables[i][j] = *newPoint().Add(&tables[i][j-1], b)
+		}
+		digits[i] = scalarDigits(nil, exps[i])
+	}
+
+	acc := newPoint() // the identity
+	for d := range 2 * int(group.Params().ScalarLength) {
+		if d > 0 {
+			for range multiExpWindow {
+				acc.Double(acc)
+			}
+		}
+		for i := range bases {
+			if digit := digits[i][d]; digit != 0 {
+				acc.Add(acc, &tables[i][digit-1])
+			}
+		}
+	}
+	if acc.IsInfinity() == 1 {
+		return identity()
+	}
+	z := new(point)
+	if err := z.elem().UnmarshalBinary(acc.Bytes()); err != nil {
+		panic(err)
+	}
+	return z
+}
@@ -3,12 +3,14 @@ module github.com/hpicrypto/mppj
 go 1.24.0
 
 require (
+	filippo.io/nistec v0.0.4
 	github.com/cloudflare/circl v1.6.0
 	github.com/google/uuid v1.6.0
 	github.com/stretchr/testify v1.10.0
 	go.dedis.ch/kyber/v4 v4.0.0-pre2
 	golang.org/x/crypto v0.40.0
 	golang.org/x/net v0.41.0
+	golang.org/x/text v0.27.0
 	google.golang.org/grpc v1.75.0
 	google.golang.org/protobuf v1.36.8
 )
@@ -19,7 +21,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.dedis.ch/fixbuf v1.0.3 // indirect
 	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
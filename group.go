@@ -7,7 +7,22 @@ import (
 	circl "github.com/cloudflare/circl/group"
 )
 
-var group = circl.P256
+// Suite identifies the prime-order group of a session, along with its message embedding. The suite is selected
+// at compile time: P-256 is used by default, and the other suites with the build tags mppj_p384,
+// mppj_ristretto255 and mppj_edwards25519. See the group_*.go files.
+type Suite string
+
+const (
+	SuiteP256         Suite = "P256"
+	SuiteP384         Suite = "P384"
+	SuiteRistretto255 Suite = "ristretto255"
+	SuiteEdwards25519 Suite = "edwards25519"
+)
+
+// CompiledSuite returns the suite the package was built with.
+func CompiledSuite() Suite {
+	return suite
+}
 
 // scalar represents a scalar value modulo the curve's order
 type scalar struct {
@@ -55,7 +70,7 @@ func identity() *point {
 
 // mul performs the group operation on two points a, b on the elliptic curve.
 func mul(a, b *point) *point {
-	return &point{p: a.p.Copy().Add(a.p, b.p)}
+	return new(point).setMul(a, b)
 }
 
 // mulBatched performs the group operation on a slice of points.
@@ -72,17 +87,60 @@ func mulBatched(pointArr []*point) *point {
 
 // baseExp exponentiates the generator by a scalar.
 func baseExp(s *scalar) *point {
-	return &point{p: group.NewElement().MulGen(s.s)}
+	return new(point).setBaseExp(s)
 }
 
 // Inverts a point a on the elliptic curve.
 func (a *point) invert() *point {
-	return &point{p: a.p.Copy().Neg(a.p)}
+	return new(point).setInvert(a)
 }
 
 // scalarExp exponentiates a point a by a scalar b on the elliptic curve.
 func (a *point) scalarExp(b *scalar) *point {
-	return &point{p: a.p.Copy().Mul(a.p, b.s)}
+	return new(point).setScalarExp(a, b)
+}
+
+// The set* methods are the in-place variants of the group operations, for the hot paths. They set z to the
+// result and return z, reusing the element of z, which is only allocated if z is the zero point. The operands
+// may alias z. As the copies of a point share its element, they must only be applied to points that own their
+// element, such as the zero point and the fields of the ciphertexts under construction.
+
+// elem returns the element of z, which is allocated if z is the zero point.
+func (z *point) elem() circl.Element {
+	if z.p == nil {
+		z.p = group.NewElement()
+	}
+	return z.p
+}
+
+// set sets z = a.
+func (z *point) set(a *point) *point {
+	z.elem().Set(a.p)
+	return z
+}
+
+// setMul sets z = a * b.
+func (z *point) setMul(a, b *point) *point {
+	z.elem().Add(a.p, b.p)
+	return z
+}
+
+// setBaseExp sets z = g^s.
+func (z *point) setBaseExp(s *scalar) *point {
+	z.elem().MulGen(s.s)
+	return z
+}
+
+// setScalarExp sets z = a^s.
+func (z *point) setScalarExp(a *point, s *scalar) *point {
+	z.elem().Mul(a.p, s.s)
+	return z
+}
+
+// setInvert sets z = a^-1.
+func (z *point) setInvert(a *point) *point {
+	z.elem().Neg(a.p)
+	return z
 }
 
 // produces a unifromly random point on the curve
@@ -101,6 +159,21 @@ func (a *scalar) add(b *scalar) *scalar {
 	return &scalar{s: a.s.Copy().Add(a.s, b.s)}
 }
 
+// Multiplies 2 scalars a, b.
+func (a *scalar) mul(b *scalar) *scalar {
+	return &scalar{s: a.s.Copy().Mul(a.s, b.s)}
+}
+
+// Subtracts scalar b from a.
+func (a *scalar) sub(b *scalar) *scalar {
+	return &scalar{s: a.s.Copy().Sub(a.s, b.s)}
+}
+
+// Inverts a non-zero scalar a.
+func (a *scalar) inv() *scalar {
+	return &scalar{s: a.s.Copy().Inv(a.s)}
+}
+
 func (a *scalar) Equals(b *scalar) bool {
 	return a.s.IsEqual(b.s)
 }
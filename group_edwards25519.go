@@ -0,0 +1,318 @@
+//go:build mppj_edwards25519
+
+package mppj
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+
+	"github.com/cloudflare/circl/expander"
+	circl "github.com/cloudflare/circl/group"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/util/random"
+	"go.dedis.ch/kyber/v4/xof/blake2xb"
+)
+
+const suite = SuiteEdwards25519
+
+// group is the prime-order subgroup of edwards25519, as implemented by kyber.
+var group circl.Group = edwardsGroup{}
+
+// keyTable is the fixed-base table with which the receiver keys are exponentiated.
+type keyTable = fixedBase
+
+// newKeyTable builds the keyTable of base.
+func newKeyTable(base *point) *keyTable {
+	return newFixedBase(base)
+}
+
+// multiExp returns the product of the bases raised to the exponents, see circlMultiExp.
+func multiExp(bases []*point, exps []*scalar) *point {
+	return circlMultiExp(bases, exps)
+}
+
+// MaxValueSize is the size in bytes of the value chunks embedded into a single group element.
+// Values shorter than MaxValueSize are embedded directly, larger values are encrypted in hybrid mode.
+const MaxValueSize = 29
+
+var (
+	edwards      = new(edwards25519.Curve)
+	edwardsOrder = new(big.Int).SetBytes([]byte{
+		0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x14, 0xde, 0xf9, 0xde, 0xa2, 0xf7, 0x9c, 0xd6, 0x58, 0x12, 0x63, 0x1a, 0x5c, 0xf5, 0xd3, 0xed,
+	})
+)
+
+// embed maps msg into the y-coordinate of a point of the prime-order subgroup, with the embedding of kyber.
+func embed(msg []byte) (*point, error) {
+	if len(msg) > MaxValueSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d bytes of the embedding", len(msg), MaxValueSize)
+	}
+	return &point{p: &edwardsElement{p: edwards.Point().Embed(msg, random.New())}}, nil
+}
+
+// extract returns the message embedded into p by embed.
+func extract(p *point) ([]byte, error) {
+	e, ok := p.p.(*edwardsElement)
+	if !ok {
+		return nil, circl.ErrType
+	}
+	return e.p.Data()
+}
+
+// edwardsGroup implements the circl group interface for the prime-order subgroup of edwards25519. Unlike the
+// circl groups, the conditional moves are not constant time.
+type edwardsGroup struct{}
+
+func (edwardsGroup) Params() *circl.Params {
+	return &circl.Params{ElementLength: 32, CompressedElementLength: 32, ScalarLength: 32}
+}
+
+func (edwardsGroup) NewElement() circl.Element {
+	return &edwardsElement{p: edwards.Point().Null()}
+}
+
+func (edwardsGroup) NewScalar() circl.Scalar {
+	return &edwardsScalar{s: edwards.Scalar().Zero()}
+}
+
+func (g edwardsGroup) Identity() circl.Element {
+	return g.NewElement()
+}
+
+func (edwardsGroup) Generator() circl.Element {
+	return &edwardsElement{p: edwards.Point().Base()}
+}
+
+func (g edwardsGroup) RandomElement(rnd io.Reader) circl.Element {
+	return g.NewElement().MulGen(g.RandomScalar(rnd))
+}
+
+// RandomScalar reduces 64 bytes of rnd modulo the group order, for a negligible bias.
+func (edwardsGroup) RandomScalar(rnd io.Reader) circl.Scalar {
+	var buf [64]byte
+	if _, err := io.ReadFull(rnd, buf[:]); err != nil {
+		panic(err)
+	}
+	return &edwardsScalar{s: edwards.Scalar().SetBytes(buf[:])}
+}
+
+func (g edwardsGroup) RandomNonZeroScalar(rnd io.Reader) circl.Scalar {
+	for {
+		if s := g.RandomScalar(rnd); !s.IsZero() {
+			return s
+		}
+	}
+}
+
+// HashToElement hashes msg to a point of the prime-order subgroup, by seeding kyber's point picking with the
+// expansion of msg. Unlike the circl groups, it does not follow RFC 9380.
+func (edwardsGroup) HashToElement(msg, dst []byte) circl.Element {
+	seed := expander.NewExpanderMD(crypto.SHA512, dst).Expand(msg, 64)
+	return &edwardsElement{p: edwards.Point().Pick(blake2xb.New(seed))}
+}
+
+func (g edwardsGroup) HashToElementNonUniform(msg, dst []byte) circl.Element {
+	return g.HashToElement(msg, dst)
+}
+
+func (edwardsGroup) HashToScalar(msg, dst []byte) circl.Scalar {
+	uniform := expander.NewExpanderMD(crypto.SHA512, dst).Expand(msg, 64)
+	return &edwardsScalar{s: edwards.Scalar().SetBytes(uniform)}
+}
+
+// edwardsElement is a point of the prime-order subgroup of edwards25519.
+type edwardsElement struct {
+	p kyber.Point
+}
+
+func (e *edwardsElement) Group() circl.Group { return group }
+
+func (e *edwardsElement) Set(x circl.Element) circl.Element {
+	e.p = x.(*edwardsElement).p.Clone()
+	return e
+}
+
+func (e *edwardsElement) Copy() circl.Element {
+	return &edwardsElement{p: e.p.Clone()}
+}
+
+func (e *edwardsElement) IsIdentity() bool {
+	return e.p.Equal(edwards.Point().Null())
+}
+
+func (e *edwardsElement) IsEqual(x circl.Element) bool {
+	return e.p.Equal(x.(*edwardsElement).p)
+}
+
+func (e *edwardsElement) CMov(b int, x circl.Element) circl.Element {
+	if b != 0 && b != 1 {
+		panic(circl.ErrSelector)
+	}
+	if b == 1 {
+		e.Set(x)
+	}
+	return e
+}
+
+func (e *edwardsElement) CSelect(b int, x, y circl.Element) circl.Element {
+	if b != 0 && b != 1 {
+		panic(circl.ErrSelector)
+	}
+	if b == 1 {
+		return e.Set(x)
+	}
+	return e.Set(y)
+}
+
+func (e *edwardsElement) Add(x, y circl.Element) circl.Element {
+	e.p = edwards.Point().Add(x.(*edwardsElement).p, y.(*edwardsElement).p)
+	return e
+}
+
+func (e *edwardsElement) Dbl(x circl.Element) circl.Element {
+	return e.Add(x, x)
+}
+
+func (e *edwardsElement) Neg(x circl.Element) circl.Element {
+	e.p = edwards.Point().Neg(x.(*edwardsElement).p)
+	return e
+}
+
+func (e *edwardsElement) Mul(x circl.Element, s circl.Scalar) circl.Element {
+	e.p = edwards.Point().Mul(s.(*edwardsScalar).s, x.(*edwardsElement).p)
+	return e
+}
+
+func (e *edwardsElement) MulGen(s circl.Scalar) circl.Element {
+	e.p = edwards.Point().Mul(s.(*edwardsScalar).s, nil)
+	return e
+}
+
+func (e *edwardsElement) MarshalBinary() ([]byte, error) {
+	return e.p.MarshalBinary()
+}
+
+func (e *edwardsElement) MarshalBinaryCompress() ([]byte, error) {
+	return e.p.MarshalBinary()
+}
+
+// UnmarshalBinary decodes a point, and rejects the non-canonical encodings and the points outside of the
+// prime-order subgroup.
+func (e *edwardsElement) UnmarshalBinary(data []byte) error {
+	p := edwards.Point()
+	if len(data) != 32 || p.UnmarshalBinary(data) != nil {
+		return circl.ErrUnmarshal
+	}
+	if canonical, err := p.MarshalBinary(); err != nil || !slices.Equal(canonical, data) {
+		return circl.ErrUnmarshal
+	}
+	minusOne := edwards.Scalar().SetInt64(-1)
+	if !edwards.Point().Add(edwards.Point().Mul(minusOne, p), p).Equal(edwards.Point().Null()) { // [l]p = 0
+		return circl.ErrUnmarshal
+	}
+	e.p = p
+	return nil
+}
+
+// edwardsScalar is a scalar modulo the order of the prime-order subgroup of edwards25519.
+type edwardsScalar struct {
+	s kyber.Scalar
+}
+
+func (s *edwardsScalar) Group() circl.Group { return group }
+
+func (s *edwardsScalar) Set(x circl.Scalar) circl.Scalar {
+	s.s = x.(*edwardsScalar).s.Clone()
+	return s
+}
+
+func (s *edwardsScalar) Copy() circl.Scalar {
+	return &edwardsScalar{s: s.s.Clone()}
+}
+
+func (s *edwardsScalar) IsZero() bool {
+	return s.s.Equal(edwards.Scalar().Zero())
+}
+
+func (s *edwardsScalar) IsEqual(x circl.Scalar) bool {
+	return s.s.Equal(x.(*edwardsScalar).s)
+}
+
+func (s *edwardsScalar) SetUint64(x uint64) circl.Scalar {
+	return s.SetBigInt(new(big.Int).SetUint64(x))
+}
+
+func (s *edwardsScalar) SetBigInt(b *big.Int) circl.Scalar {
+	le := new(big.Int).Mod(b, edwardsOrder).FillBytes(make([]byte, 32))
+	slices.Reverse(le)
+	s.s = edwards.Scalar().SetBytes(le)
+	return s
+}
+
+func (s *edwardsScalar) CMov(b int, x circl.Scalar) circl.Scalar {
+	if b != 0 && b != 1 {
+		panic(circl.ErrSelector)
+	}
+	if b == 1 {
+		s.Set(x)
+	}
+	return s
+}
+
+func (s *edwardsScalar) CSelect(b int, x, y circl.Scalar) circl.Scalar {
+	if b != 0 && b != 1 {
+		panic(circl.ErrSelector)
+	}
+	if b == 1 {
+		return s.Set(x)
+	}
+	return s.Set(y)
+}
+
+func (s *edwardsScalar) Add(x, y circl.Scalar) circl.Scalar {
+	s.s = edwards.Scalar().Add(x.(*edwardsScalar).s, y.(*edwardsScalar).s)
+	return s
+}
+
+func (s *edwardsScalar) Sub(x, y circl.Scalar) circl.Scalar {
+	s.s = edwards.Scalar().Sub(x.(*edwardsScalar).s, y.(*edwardsScalar).s)
+	return s
+}
+
+func (s *edwardsScalar) Mul(x, y circl.Scalar) circl.Scalar {
+	s.s = edwards.Scalar().Mul(x.(*edwardsScalar).s, y.(*edwardsScalar).s)
+	return s
+}
+
+func (s *edwardsScalar) Neg(x circl.Scalar) circl.Scalar {
+	s.s = edwards.Scalar().Neg(x.(*edwardsScalar).s)
+	return s
+}
+
+func (s *edwardsScalar) Inv(x circl.Scalar) circl.Scalar {
+	s.s = edwards.Scalar().Inv(x.(*edwardsScalar).s)
+	return s
+}
+
+func (s *edwardsScalar) MarshalBinary() ([]byte, error) {
+	return s.s.MarshalBinary()
+}
+
+// UnmarshalBinary decodes a little-endian scalar, and rejects the values that are not reduced.
+func (s *edwardsScalar) UnmarshalBinary(data []byte) error {
+	if len(data) != 32 {
+		return circl.ErrUnmarshal
+	}
+	be := slices.Clone(data)
+	slices.Reverse(be)
+	if new(big.Int).SetBytes(be).Cmp(edwardsOrder) >= 0 {
+		return circl.ErrUnmarshal
+	}
+	s.s = edwards.Scalar()
+	return s.s.UnmarshalBinary(data)
+}
@@ -0,0 +1,37 @@
+//go:build mppj_p384
+
+package mppj
+
+import (
+	"crypto/elliptic"
+
+	"filippo.io/nistec"
+	circl "github.com/cloudflare/circl/group"
+)
+
+const suite = SuiteP384
+
+var group = circl.P384
+
+var curve = elliptic.P384()
+
+// fieldLimbs is the number of 64-bit limbs of the field elements of the curve.
+const fieldLimbs = 6
+
+// keyTable is the fixed-base table with which the receiver keys are exponentiated, built on the points of
+// nistec, see nistPoint.
+type keyTable = nistFixedBase[nistec.P384Point, *nistec.P384Point]
+
+// newKeyTable builds the keyTable of base.
+func newKeyTable(base *point) *keyTable {
+	return newNISTFixedBase(base, nistec.NewP384Point)
+}
+
+// multiExp returns the product of the bases raised to the exponents, see nistMultiExp.
+func multiExp(bases []*point, exps []*scalar) *point {
+	return nistMultiExp(bases, exps, nistec.NewP384Point)
+}
+
+// MaxValueSize is the size in bytes of the value chunks embedded into a single group element.
+// Values shorter than MaxValueSize are embedded directly, larger values are encrypted in hybrid mode.
+const MaxValueSize = 46
@@ -0,0 +1,77 @@
+//go:build mppj_ristretto255
+
+package mppj
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	circl "github.com/cloudflare/circl/group"
+)
+
+const suite = SuiteRistretto255
+
+var group = circl.Ristretto255
+
+// keyTable is the fixed-base table with which the receiver keys are exponentiated.
+type keyTable = fixedBase
+
+// newKeyTable builds the keyTable of base.
+func newKeyTable(base *point) *keyTable {
+	return newFixedBase(base)
+}
+
+// multiExp returns the product of the bases raised to the exponents, see circlMultiExp.
+func multiExp(bases []*point, exps []*scalar) *point {
+	return circlMultiExp(bases, exps)
+}
+
+// MaxValueSize is the size in bytes of the value chunks embedded into a single group element.
+// Values shorter than MaxValueSize are embedded directly, larger values are encrypted in hybrid mode.
+const MaxValueSize = 29
+
+// embedTries bounds the number of encodings tried by embed. Each random encoding decodes to a point with
+// probability about 1/4.
+const embedTries = 256
+
+// embed maps msg into the canonical encoding of a ristretto255 element, as len(msg) << 1 || msg || rnd, where
+// rnd is random padding resampled until the encoding is valid. As the group has prime order, every valid
+// encoding is a group element, and the encoding of the element is the one from which it was decoded.
+func embed(msg []byte) (*point, error) {
+	if len(msg) > MaxValueSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d bytes of the embedding", len(msg), MaxValueSize)
+	}
+
+	var buf [32]byte
+	for range embedTries {
+		if _, err := rand.Read(buf[1+len(msg):]); err != nil {
+			return nil, err
+		}
+		buf[0] = byte(len(msg)) << 1 // the encoding of a valid element is even
+		copy(buf[1:], msg)
+		buf[31] &= 0x7f // and smaller than 2^255
+
+		result := newPoint()
+		if err := result.UnmarshalBinary(buf[:]); err == nil {
+			return result, nil
+		}
+	}
+
+	return nil, errors.New("Failed to find a valid message point")
+}
+
+// extract returns the message embedded into p by embed.
+func extract(p *point) ([]byte, error) {
+	serialized, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(serialized[0] >> 1)
+	if n > MaxValueSize {
+		return nil, fmt.Errorf("failed to unmarshal message point")
+	}
+
+	return serialized[1 : 1+n], nil
+}
@@ -239,11 +239,7 @@ func TestEncrypt(t *testing.T) {
 	_, pk := keyGenPKE()
 
 	ciphertext := encryptPKE(pk, msg)
-	if ciphertext == nil {
-		t.Fatalf("Encrypt() returned nil ciphertext")
-	}
-
-	if ciphertext.c0 == nil || ciphertext.c1 == nil {
+	if ciphertext.c0.p == nil || ciphertext.c1.p == nil {
 		t.Fatalf("Encrypt() returned ciphertext with nil components")
 	}
 }
@@ -285,7 +281,7 @@ func TestSerializeDeserializeCiphertext(t *testing.T) {
 	point1 := randomPoint()
 	point2 := randomPoint()
 
-	ciphertext := &Ciphertext{c0: point1, c1: point2}
+	ciphertext := &Ciphertext{c0: *point1, c1: *point2}
 
 	// Serialize the ciphertext
 	serializedCiphertext, err := ciphertext.Serialize()
@@ -311,7 +307,7 @@ func TestSerializeDeserializeCiphertext2(t *testing.T) {
 
 	c1 := randomPoint()
 
-	ciphertext := &Ciphertext{c0: c0, c1: c1}
+	ciphertext := &Ciphertext{c0: *c0, c1: *c1}
 
 	// Serialize the ciphertext
 	serializedCiphertext, err := ciphertext.Serialize()
@@ -434,3 +430,129 @@ func TestPlantextSecretSharing(t *testing.T) {
 		t.Errorf("Secrets do not match: %s != %s", rp, recovered_rp)
 	}
 }
+
+func TestSessionSuite(t *testing.T) {
+	_, rpk := KeyGen()
+	sources := []PartyID{"ds1", "ds2"}
+	sid := NewSessionID(sources, "helper", "receiver")
+
+	sess, err := NewSessionWithID(sid, sources, "helper", "receiver", rpk)
+	if err != nil {
+		t.Fatalf("NewSessionWithID() error = %v", err)
+	}
+	if sess.Suite != CompiledSuite() {
+		t.Errorf("Suite = %s, want %s", sess.Suite, CompiledSuite())
+	}
+	if _, err := NewSessionWithID(sid, sources, "helper", "receiver", rpk, WithSuite(CompiledSuite())); err != nil {
+		t.Errorf("NewSessionWithID() error = %v", err)
+	}
+
+	for _, other := range []Suite{SuiteP256, SuiteP384, SuiteRistretto255, SuiteEdwards25519} {
+		if other == CompiledSuite() {
+			continue
+		}
+		if _, err := NewSessionWithID(sid, sources, "helper", "receiver", rpk, WithSuite(other)); err == nil {
+			t.Errorf("expected an error for suite %s in a %s build", other, CompiledSuite())
+		}
+	}
+}
+
+func TestFixedBase(t *testing.T) {
+	base := randomPoint()
+	tables := map[string]interface {
+		expInto(z *point, s *scalar) *point
+	}{
+		"fixedBase": newFixedBase(base),
+		"keyTable":  newKeyTable(base),
+	}
+
+	scalars := []*scalar{
+		newScalar(big.NewInt(0)),
+		newScalar(big.NewInt(1)),
+		newScalar(big.NewInt(15)),
+		newScalar(big.NewInt(16)),
+		newScalar(big.NewInt(1)).neg(),
+	}
+	for range 20 {
+		scalars = append(scalars, randomScalar())
+	}
+
+	for name, table := range tables {
+		for i, s := range scalars {
+			if got, want := table.expInto(new(point), s), base.scalarExp(s); !got.Equals(want) {
+				t.Errorf("%s.expInto() = %v, want %v for scalar %d", name, got, want, i)
+			}
+		}
+	}
+}
+
+func TestMultiExp(t *testing.T) {
+	bases := []*point{randomPoint(), identity(), randomPoint(), gen()}
+	exps := []*scalar{
+		newScalar(big.NewInt(0)),
+		randomScalar(),
+		newScalar(big.NewInt(1)).neg(),
+		newScalar(big.NewInt(16)),
+	}
+	for range 20 {
+		bases, exps = append(bases, randomPoint()), append(exps, randomScalar())
+	}
+
+	want := identity()
+	for i := range bases {
+		want = mul(want, bases[i].scalarExp(exps[i]))
+	}
+	multiExps := map[string]func([]*point, []*scalar) *point{"multiExp": multiExp, "circlMultiExp": circlMultiExp}
+	for name, f := range multiExps {
+		if got := f(bases, exps); !got.Equals(want) {
+			t.Errorf("%s() = %v, want %v", name, got, want)
+		}
+		if got := f(append(bases, want), append(exps, newScalar(big.NewInt(1)).neg())); !got.Equals(identity()) {
+			t.Errorf("%s() = %v, want the identity", name, got)
+		}
+		if got := f(nil, nil); !got.Equals(identity()) {
+			t.Errorf("%s() = %v, want the identity for no bases", name, got)
+		}
+	}
+}
+
+func BenchmarkMultiExp(b *testing.B) {
+	bases, exps := make([]*point, 64), make([]*scalar, 64)
+	for i := range bases {
+		bases[i], exps[i] = randomPoint(), randomScalar()
+	}
+
+	b.Run("ScalarExp", func(b *testing.B) {
+		for b.Loop() {
+			acc := identity()
+			for i := range bases {
+				acc.setMul(acc, bases[i].scalarExp(exps[i]))
+			}
+		}
+	})
+
+	b.Run("MultiExp", func(b *testing.B) {
+		for b.Loop() {
+			multiExp(bases, exps)
+		}
+	})
+}
+
+func BenchmarkPublicKeyExp(b *testing.B) {
+	_, rpk := KeyGen()
+	s := randomScalar()
+
+	b.Run("ScalarExp", func(b *testing.B) {
+		for b.Loop() {
+			rpk.bpk.scalarExp(s)
+		}
+	})
+
+	b.Run("KeyTable", func(b *testing.B) {
+		table := newKeyTable(&rpk.bpk.point)
+		b.ResetTimer()
+		for b.Loop() {
+			table.expInto(new(point), s)
+		}
+	})
+}
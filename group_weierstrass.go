@@ -0,0 +1,278 @@
+//go:build !mppj_ristretto255 && !mppj_edwards25519
+
+package mppj
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// The embedding maps a message msg into the x-coordinate of a point of the NIST curve, as the integer
+// 0x04 || msg || ctr, where ctr is the first counter value for which x is the abscissa of a point, and
+// y = (x^3 - 3x + b)^((p+1)/4) is the square root returned by big.Int.ModSqrt. The counter starts at 0x02,
+// and is incremented as an integer.
+//
+// The candidates are evaluated with constant-time field arithmetic. The first embedTries candidates are always
+// evaluated, so that the running time does not depend on the message, except with probability 2^-embedTries,
+// in which case the following candidates are evaluated until a point is found.
+
+// embedTries is the number of candidates that are always evaluated by embed.
+const embedTries = 32
+
+// maxEmbedTries is the number of candidates after which embed fails, as the counter is one byte.
+const maxEmbedTries = 255
+
+// fieldElement is a field element in Montgomery form, as little-endian limbs.
+type fieldElement [fieldLimbs]uint64
+
+// montField implements constant-time Montgomery arithmetic modulo the prime p of the curve.
+type montField struct {
+	size    int // byte length of the field elements
+	p       fieldElement
+	pInv    uint64 // -p^-1 mod 2^64
+	rr      fieldElement
+	one     fieldElement
+	b       fieldElement
+	sqrtExp []byte // (p+1)/4, big-endian
+}
+
+var field = newMontField(curve.Params().P, curve.Params().B)
+
+func newMontField(p, b *big.Int) *montField {
+	f := &montField{size: (p.BitLen() + 7) / 8}
+	f.p = limbs(p)
+
+	// -p^-1 mod 2^64 by Newton iteration, as p is odd
+	inv := uint64(1)
+	for range 6 {
+		inv *= 2 - f.p[0]*inv
+	}
+	f.pInv = -inv
+
+	r := new(big.Int).Lsh(big.NewInt(1), uint(64*fieldLimbs))
+	f.one = limbs(new(big.Int).Mod(r, p))
+	f.rr = limbs(new(big.Int).Mod(new(big.Int).Mul(r, r), p))
+	f.b = f.toMont(limbs(b))
+	f.sqrtExp = new(big.Int).Rsh(new(big.Int).Add(p, big.NewInt(1)), 2).Bytes()
+	return f
+}
+
+// limbs returns the little-endian limbs of a non-negative integer that fits in fieldLimbs limbs.
+func limbs(x *big.Int) fieldElement {
+	var buf [8 * fieldLimbs]byte
+	x.FillBytes(buf[:])
+	return limbsFromBytes(buf[:])
+}
+
+// limbsFromBytes returns the little-endian limbs of a big-endian integer of at most 8*fieldLimbs bytes.
+func limbsFromBytes(b []byte) fieldElement {
+	var z fieldElement
+	for i := range b {
+		pos := len(b) - 1 - i
+		z[pos/8] |= uint64(b[i]) << (8 * (pos % 8))
+	}
+	return z
+}
+
+// fillBytes writes the big-endian encoding of the limbs of z into b.
+func (z *fieldElement) fillBytes(b []byte) {
+	for i := range b {
+		pos := len(b) - 1 - i
+		b[i] = byte(z[pos/8] >> (8 * (pos % 8)))
+	}
+}
+
+// mul sets z = x * y * R^-1 mod p, with the CIOS method.
+func (f *montField) mul(z, x, y *fieldElement) {
+	const n = fieldLimbs
+	a, b, p := *x, *y, f.p
+	var t [n + 1]uint64
+	var t1 uint64
+	for i := range n {
+		var c, cc uint64
+		for j := range n {
+			hi, lo := bits.Mul64(a[j], b[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			t[j], c = lo, hi+cc
+		}
+		t[n], t1 = bits.Add64(t[n], c, 0)
+
+		m := t[0] * f.pInv
+		hi, lo := bits.Mul64(m, p[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < n; j++ {
+			hi, lo = bits.Mul64(m, p[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			t[j-1], c = lo, hi+cc
+		}
+		t[n-1], cc = bits.Add64(t[n], c, 0)
+		t[n] = t1 + cc
+	}
+	f.reduce(z, (*[n]uint64)(t[:n]), t[n])
+}
+
+// reduce sets z = t - p if t = (carry, t) >= p, and z = t otherwise, for t < 2p.
+func (f *montField) reduce(z *fieldElement, t *[fieldLimbs]uint64, carry uint64) {
+	var d fieldElement
+	var borrow uint64
+	for j := range fieldLimbs {
+		d[j], borrow = bits.Sub64(t[j], f.p[j], borrow)
+	}
+	_, borrow = bits.Sub64(carry, 0, borrow)
+	mask := -borrow // all ones if t < p
+	for j := range fieldLimbs {
+		z[j] = t[j]&mask | d[j]&^mask
+	}
+}
+
+// add sets z = x + y mod p.
+func (f *montField) add(z, x, y *fieldElement) {
+	var t fieldElement
+	var carry uint64
+	for j := range fieldLimbs {
+		t[j], carry = bits.Add64(x[j], y[j], carry)
+	}
+	f.reduce(z, (*[fieldLimbs]uint64)(&t), carry)
+}
+
+// sub sets z = x - y mod p.
+func (f *montField) sub(z, x, y *fieldElement) {
+	var borrow, carry uint64
+	var t fieldElement
+	for j := range fieldLimbs {
+		t[j], borrow = bits.Sub64(x[j], y[j], borrow)
+	}
+	mask := -borrow // all ones if x < y, in which case p is added back
+	for j := range fieldLimbs {
+		z[j], carry = bits.Add64(t[j], f.p[j]&mask, carry)
+	}
+}
+
+// equal returns 1 if x = y, and 0 otherwise.
+func (f *montField) equal(x, y *fieldElement) uint64 {
+	var acc uint64
+	for j := range fieldLimbs {
+		acc |= x[j] ^ y[j]
+	}
+	return 1 ^ (acc|-acc)>>63
+}
+
+// sqrtCandidate sets z = x^((p+1)/4), which is a square root of x if x is a square, as p = 3 mod 4. The
+// exponent is public, and is processed in windows of 4 bits.
+func (f *montField) sqrtCandidate(z, x *fieldElement) {
+	var table [16]fieldElement
+	table[0], table[1] = f.one, *x
+	for i := 2; i < len(table); i++ {
+		f.mul(&table[i], &table[i-1], x)
+	}
+
+	r := f.one
+	for _, b := range f.sqrtExp {
+		for _, w := range [2]byte{b >> 4, b & 0x0f} {
+			for range 4 {
+				f.mul(&r, &r, &r)
+			}
+			if w != 0 {
+				f.mul(&r, &r, &table[w])
+			}
+		}
+	}
+	*z = r
+}
+
+func (f *montField) toMont(x fieldElement) fieldElement {
+	var z fieldElement
+	f.mul(&z, &x, &f.rr)
+	return z
+}
+
+func (f *montField) fromMont(x *fieldElement) fieldElement {
+	var z, one fieldElement
+	one[0] = 1
+	f.mul(&z, x, &one)
+	return z
+}
+
+// embed maps msg into a point of the NIST curve, see above.
+func embed(msg []byte) (*point, error) {
+	f := field
+	if len(msg) > f.size-2 {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d bytes of the embedding", len(msg), f.size-2)
+	}
+
+	var buf [1 + 2*8*fieldLimbs]byte
+	xBytes := buf[1 : 1+f.size]
+	xBytes[f.size-len(msg)-2] = 0x04
+	copy(xBytes[f.size-len(msg)-1:], msg)
+	xBytes[f.size-1] = 0x02
+
+	x := f.toMont(limbsFromBytes(xBytes))
+	var three, x3, threeX, a, y, y2, resX, resY fieldElement
+	f.add(&three, &f.one, &f.one)
+	f.add(&three, &three, &f.one)
+
+	var found uint64
+	for i := 0; i < embedTries || found == 0 && i < maxEmbedTries; i++ {
+		// a = x^3 - 3x + b
+		f.mul(&x3, &x, &x)
+		f.mul(&x3, &x3, &x)
+		f.mul(&threeX, &three, &x)
+		f.sub(&a, &x3, &threeX)
+		f.add(&a, &a, &f.b)
+
+		f.sqrtCandidate(&y, &a)
+		f.mul(&y2, &y, &y)
+		ok := f.equal(&y2, &a) &^ found
+		mask := -ok
+		for j := range fieldLimbs {
+			resX[j] = x[j]&mask | resX[j]&^mask
+			resY[j] = y[j]&mask | resY[j]&^mask
+		}
+		found |= ok
+
+		f.add(&x, &x, &f.one)
+	}
+	if found == 0 {
+		return nil, errors.New("Failed to find a valid message point")
+	}
+
+	buf[0] = 0x04 // uncompressed encoding
+	xb, yb := f.fromMont(&resX), f.fromMont(&resY)
+	xb.fillBytes(buf[1 : 1+f.size])
+	yb.fillBytes(buf[1+f.size : 1+2*f.size])
+	result := newPoint()
+	if err := result.UnmarshalBinary(buf[:1+2*f.size]); err != nil {
+		return nil, err // when using a compatible curve, this should never happen
+	}
+
+	return result, nil
+}
+
+// extract returns the message embedded into p by embed, read from the x-coordinate of its compressed encoding.
+func extract(p *point) ([]byte, error) {
+	serialized, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(serialized) != 1+field.size {
+		return nil, fmt.Errorf("failed to unmarshal message point")
+	}
+	x := serialized[1:]
+
+	start := 0
+	for start < len(x) && x[start] == 0 { // the leading zeros only depend on the message length
+		start++
+	}
+	if start >= len(x)-1 {
+		return nil, fmt.Errorf("failed to unmarshal message point")
+	}
+
+	return x[start+1 : len(x)-1], nil // Remove the prefix and LSB
+}
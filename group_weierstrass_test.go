@@ -0,0 +1,132 @@
+//go:build !mppj_ristretto255 && !mppj_edwards25519
+
+package mppj
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// embedBigInt is the former variable-time embedding, kept as a reference.
+func embedBigInt(msg []byte) (*point, error) {
+	params := curve.Params()
+
+	msgBytes := append([]byte{0x04}, append(bytes.Clone(msg), 0x02)...)
+	msgInt := new(big.Int).SetBytes(msgBytes)
+
+	for i := 1; ; i++ {
+		x3 := new(big.Int).Mul(msgInt, msgInt)
+		x3.Mul(x3, msgInt)
+		threeX := new(big.Int).Lsh(msgInt, 1)
+		threeX.Add(threeX, msgInt)
+		x3.Sub(x3, threeX)
+		x3.Add(x3, params.B)
+		x3.Mod(x3, params.P)
+
+		if y := new(big.Int).ModSqrt(x3, params.P); y != nil {
+			result := newPoint()
+			if err := result.UnmarshalBinary(elliptic.Marshal(curve, msgInt, y)); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+		if i == 255 {
+			return nil, errors.New("Failed to find a valid message point")
+		}
+		msgInt.Add(msgInt, big.NewInt(1))
+	}
+}
+
+// extractBigInt is the former extraction, kept as a reference.
+func extractBigInt(p *point) []byte {
+	serialized, _ := p.MarshalBinary()
+	x, _ := elliptic.UnmarshalCompressed(curve, serialized)
+	msgBytes := x.Bytes()
+	return msgBytes[1 : len(msgBytes)-1]
+}
+
+func TestEmbedMatchesBigInt(t *testing.T) {
+	messages := [][]byte{{0}, {1}, bytes.Repeat([]byte{0}, MaxValueSize), bytes.Repeat([]byte{0xff}, MaxValueSize)}
+	for size := 1; size <= MaxValueSize; size++ {
+		for range 20 {
+			msg := make([]byte, size)
+			if _, err := rand.Read(msg); err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	for _, msg := range messages {
+		got, err := embed(msg)
+		if err != nil {
+			t.Fatalf("embed(%x) error = %v", msg, err)
+		}
+		want, err := embedBigInt(msg)
+		if err != nil {
+			t.Fatalf("embedBigInt(%x) error = %v", msg, err)
+		}
+		if !got.Equals(want) {
+			t.Errorf("embed(%x) differs from the big.Int embedding", msg)
+		}
+
+		extracted, err := extract(got)
+		if err != nil {
+			t.Fatalf("extract() error = %v", err)
+		}
+		if !bytes.Equal(extracted, msg) || !bytes.Equal(extracted, extractBigInt(got)) {
+			t.Errorf("extract() = %x, want %x", extracted, msg)
+		}
+	}
+
+	if _, err := embed(make([]byte, MaxValueSize+1)); err == nil {
+		t.Errorf("expected an error for messages longer than MaxValueSize")
+	}
+}
+
+func TestMontField(t *testing.T) {
+	p := curve.Params().P
+	for range 100 {
+		a, _ := rand.Int(rand.Reader, p)
+		b, _ := rand.Int(rand.Reader, p)
+		x, y := field.toMont(limbs(a)), field.toMont(limbs(b))
+
+		var z fieldElement
+		for _, op := range []struct {
+			name string
+			f    func(z, x, y *fieldElement)
+			want *big.Int
+		}{
+			{"mul", field.mul, new(big.Int).Mul(a, b)},
+			{"add", field.add, new(big.Int).Add(a, b)},
+			{"sub", field.sub, new(big.Int).Sub(a, b)},
+		} {
+			op.f(&z, &x, &y)
+			if got, want := field.fromMont(&z), limbs(op.want.Mod(op.want, p)); got != want {
+				t.Errorf("%s(%v, %v) mismatch", op.name, a, b)
+			}
+		}
+	}
+}
+
+func BenchmarkEmbedConstantTime(b *testing.B) {
+	msg := make([]byte, MaxValueSize)
+	for b.Loop() {
+		if _, err := embed(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEmbedBigInt(b *testing.B) {
+	msg := make([]byte, MaxValueSize)
+	for b.Loop() {
+		if _, err := embedBigInt(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mppj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// CompositeKey represents a join key made of several identifier columns, such as (first name, last name,
+// date of birth). Its encoding is canonical and injective, so that distinct keys never collide, as opposed
+// to the concatenation of their parts.
+type CompositeKey []string
+
+// Encode returns the canonical encoding of the key: the number of parts, followed by each part prefixed
+// with its length, all lengths being encoded as uvarints.
+func (k CompositeKey) Encode() []byte {
+	enc := binary.AppendUvarint(nil, uint64(len(k)))
+	for _, part := range k {
+		enc = binary.AppendUvarint(enc, uint64(len(part)))
+		enc = append(enc, part...)
+	}
+	return enc
+}
+
+// UID returns the encoding of the key as a string, to be used as the UID of a row in the plain tables.
+func (k CompositeKey) UID() string {
+	return string(k.Encode())
+}
+
+// ParseCompositeKey parses a UID obtained from [CompositeKey.UID]. It returns an error if the UID is not
+// the canonical encoding of a composite key.
+func ParseCompositeKey(uid string) (CompositeKey, error) {
+	data := []byte(uid)
+	nParts, n := binary.Uvarint(data)
+	if n <= 0 || nParts > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid composite key encoding")
+	}
+	data = data[n:]
+
+	k := make(CompositeKey, nParts)
+	for i := range k {
+		partLen, n := binary.Uvarint(data)
+		if n <= 0 || partLen > uint64(len(data)-n) {
+			return nil, fmt.Errorf("invalid composite key encoding")
+		}
+		k[i] = string(data[n : n+int(partLen)])
+		data = data[n+int(partLen):]
+	}
+	if len(data) != 0 || !bytes.Equal(k.Encode(), []byte(uid)) {
+		return nil, fmt.Errorf("non-canonical composite key encoding")
+	}
+	return k, nil
+}
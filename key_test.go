@@ -0,0 +1,101 @@
+package mppj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompositeKeyEncoding(t *testing.T) {
+	keys := []CompositeKey{
+		{"Alice", "Smith", "1990-01-01"},
+		{"ab", "c"},
+		{"a", "bc"},
+		{"a|b", "c"},
+		{"a", "b|c"},
+		{"", "abc"},
+		{"abc", ""},
+		{"abc"},
+		{},
+	}
+
+	encodings := make(map[string]CompositeKey, len(keys))
+	for _, key := range keys {
+		uid := key.UID()
+		if other, exists := encodings[uid]; exists {
+			t.Errorf("Keys %q and %q have the same encoding", key, other)
+		}
+		encodings[uid] = key
+
+		parsed, err := ParseCompositeKey(uid)
+		require.NoError(t, err, "ParseCompositeKey() error")
+		require.Equal(t, len(key), len(parsed))
+		for i := range key {
+			require.Equal(t, key[i], parsed[i])
+		}
+	}
+
+	for _, invalid := range []string{"", "\x02\x01a", "\x01\x01ab", "\x81\x00\x00", "plain-uid"} {
+		if _, err := ParseCompositeKey(invalid); err == nil {
+			t.Errorf("ParseCompositeKey(%q) expected an error", invalid)
+		}
+	}
+}
+
+func TestMPPJCompositeKeys(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithKeySchema("first", "last", "dob"))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	tables := map[PartyID]TablePlain{
+		"ds1": {
+			CompositeKey{"Alice", "Smith", "1990-01-01"}.UID(): "a1",
+			CompositeKey{"Bob", "Jones", "1985-05-05"}.UID():   "b1",
+			CompositeKey{"ab", "c", "2000-01-01"}.UID():        "c1",
+		},
+		"ds2": {
+			CompositeKey{"Alice", "Smith", "1990-01-01"}.UID(): "a2",
+			CompositeKey{"Bob", "Jones", "1985-06-06"}.UID():   "b2",
+			CompositeKey{"a", "bc", "2000-01-01"}.UID():        "c2",
+		},
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	require.NoError(t, err, "Convert() error")
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+
+	joinedTablesPlain := IntersectPlain(tables, sourceIDs)
+	if intersectionMPPJ.Len() != 1 || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+
+	if _, err := ds.ProcessRow(CompositeKey{"Alice", "Smith"}.UID(), "v"); err == nil {
+		t.Errorf("Expected an error for a composite key not matching the schema")
+	}
+	if _, err := ds.ProcessRow("Alice|Smith|1990-01-01", "v"); err == nil {
+		t.Errorf("Expected an error for a UID that is not a composite key")
+	}
+
+	// the key schema is bound into the session ID
+	other, err := NewSessionWithID(sess.ID, sourceIDs, "helper", "receiver", rpk, WithKeySchema("first", "last"))
+	require.NoError(t, err, "NewSessionWithID() error")
+	require.NotEqual(t, sess.ID, other.ID)
+	require.Error(t, helper.CheckSessionID(NewDataSource(other).SessionID()))
+}
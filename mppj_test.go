@@ -1,6 +1,14 @@
 package mppj
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -61,3 +69,722 @@ func TestMPPJ(t *testing.T) {
 	}
 
 }
+
+func TestMPPJLargeValues(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	tables := GenTestTables(sourceIDs, ROW_AMOUNT, INTERSECTION_SIZE)
+	for _, table := range tables {
+		for uid, val := range table {
+			table[uid] = strings.Repeat(val, 1024/len(val)+1)
+		}
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		if err != nil {
+			t.Fatalf("Error in Prepare: %v", err)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	joinedTablesPlain := IntersectPlain(tables, sourceIDs)
+	if joinedTablesPlain.Len() != INTERSECTION_SIZE || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+}
+
+func TestMPPJMultiColumn(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	columns := map[PartyID][]string{
+		"ds1": {"name", "email"},
+		"ds2": {"country", "city", "zip"},
+		"ds3": {"value"},
+	}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk,
+		WithColumns("ds1", columns["ds1"]...), WithColumns("ds2", columns["ds2"]...), WithColumns("ds3", columns["ds3"]...))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	tables := make(map[PartyID]MultiTablePlain, len(sourceIDs))
+	for sourceID, table := range GenTestTables(sourceIDs, ROW_AMOUNT, INTERSECTION_SIZE) {
+		cols := columns[sourceID]
+		multi := MultiTablePlain{Columns: cols}
+		for uid, val := range table {
+			vals := make([]string, len(cols))
+			for i, col := range cols {
+				vals[i] = col + "_" + val
+			}
+			multi.Rows = append(multi.Rows, Row{UID: uid, Values: vals})
+		}
+		tables[sourceID] = multi
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.PrepareMulti(table)
+		if err != nil {
+			t.Fatalf("Error in PrepareMulti: %v", err)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	wantColumns := []string{"ds1.name", "ds1.email", "ds2.country", "ds2.city", "ds2.zip", "ds3.value"}
+	if cols := intersectionMPPJ.Columns(); !slices.Equal(cols, wantColumns) {
+		t.Errorf("Expected columns %v, got %v", wantColumns, cols)
+	}
+
+	joinedTablesPlain := IntersectPlainMulti(tables, sourceIDs)
+	if joinedTablesPlain.Len() != INTERSECTION_SIZE || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+}
+
+func TestMultiTablePlainValidate(t *testing.T) {
+	table := MultiTablePlain{Columns: []string{"a", "b"}, Rows: []Row{{UID: "u1", Values: []string{"1", "2"}}}}
+	if err := table.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	table.Rows = append(table.Rows, Row{UID: "u2", Values: []string{"1"}})
+	if err := table.Validate(); err == nil {
+		t.Errorf("Validate() expected an error for a row with missing values")
+	}
+	table.Rows[1] = Row{UID: "u1", Values: []string{"3", "4"}}
+	if err := table.Validate(); err != nil {
+		t.Errorf("Validate() error = %v for duplicate UIDs", err)
+	}
+}
+
+func TestMPPJManySources(t *testing.T) {
+
+	sourceIDs := make([]PartyID, 300)
+	for i := range sourceIDs {
+		sourceIDs[i] = PartyID("ds" + strconv.Itoa(i+1))
+	}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	tables := GenTestTables(sourceIDs, 2, 1)
+	for sourceID, table := range tables {
+		table["join_key_0"] = "value_of_" + string(sourceID) // distinct values to detect misattributed origins
+	}
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		if err != nil {
+			t.Fatalf("Error in Prepare: %v", err)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	joinedTablesPlain := IntersectPlain(tables, sourceIDs)
+	if intersectionMPPJ.Len() != 1 || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+}
+
+func TestNewSessionSources(t *testing.T) {
+	_, rpk := KeyGen()
+
+	if _, err := NewSession([]PartyID{"ds1", "ds2", "ds1"}, "helper", "receiver", rpk); err == nil {
+		t.Errorf("Expected an error for duplicate sources")
+	}
+
+	sourceIDs := make([]PartyID, MaxSources+1)
+	for i := range sourceIDs {
+		sourceIDs[i] = PartyID("ds" + strconv.Itoa(i+1))
+	}
+	if _, err := NewSession(sourceIDs[:MaxSources], "helper", "receiver", rpk); err != nil {
+		t.Errorf("Failed to create session with %d sources: %v", MaxSources, err)
+	}
+	if _, err := NewSession(sourceIDs, "helper", "receiver", rpk); err == nil {
+		t.Errorf("Expected an error for more than %d sources", MaxSources)
+	}
+}
+
+func TestSessionIDParameters(t *testing.T) {
+	_, rpk := KeyGen()
+	_, otherRPK := KeyGen()
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	sid := NewSessionID(sourceIDs, "helper", "receiver")
+	signPK, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKeys := func(sources ...PartyID) []SessionOption {
+		opts := make([]SessionOption, len(sources))
+		for i, source := range sources {
+			opts[i] = WithSourceKey(source, signPK)
+		}
+		return opts
+	}
+
+	base, err := NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	same, err := NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk)
+	if err != nil || !bytes.Equal(base.ID, same.ID) {
+		t.Fatalf("Expected the same parameters to give the same session ID")
+	}
+
+	ids := map[string]string{string(base.ID): "base"}
+	for name, sess := range map[string]func() (*Session, error){
+		"sources": func() (*Session, error) {
+			return NewSessionWithID(sid, []PartyID{"ds2", "ds1", "ds3"}, "helper", "receiver", rpk)
+		},
+		"helper":     func() (*Session, error) { return NewSessionWithID(sid, sourceIDs, "other", "receiver", rpk) },
+		"receiverPK": func() (*Session, error) { return NewSessionWithID(sid, sourceIDs, "helper", "receiver", otherRPK) },
+		"columns": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithColumns("ds1", "a", "b"))
+		},
+		"columnNames": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithColumns("ds1", "a", "c"))
+		},
+		"threshold": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithThreshold(2))
+		},
+		"anchor": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithAnchor("ds1"))
+		},
+		"keySchema": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithKeySchema("a", "b"))
+		},
+		"normalize": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithNormalization(TrimSpace()))
+		},
+		"cardinality": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithCardinalityOnly())
+		},
+		"helperProof": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithHelperProofs())
+		},
+		"sourceProof": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithSourceProofs())
+		},
+		"sourceKeys": func() (*Session, error) {
+			return NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, withKeys(sourceIDs...)...)
+		},
+	} {
+		other, err := sess()
+		if err != nil {
+			t.Fatalf("Failed to create session %s: %v", name, err)
+		}
+		if prev, exists := ids[string(other.ID)]; exists {
+			t.Errorf("Sessions %s and %s have the same ID", name, prev)
+		}
+		ids[string(other.ID)] = name
+	}
+}
+
+func TestMPPJPadding(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	paddings := map[PartyID]Padding{
+		"ds1": PadToPowerOfTwo(),
+		"ds2": PadToSize(32),
+		"ds3": PadToSize(ROW_AMOUNT),
+	}
+	wantSizes := map[PartyID]int{"ds1": 16, "ds2": 32, "ds3": ROW_AMOUNT}
+
+	tables := GenTestTables(sourceIDs, ROW_AMOUNT, INTERSECTION_SIZE)
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		ds := NewDataSource(sess, WithPadding(paddings[sourceID]))
+		prepTable, err := ds.Prepare(table)
+		if err != nil {
+			t.Fatalf("Error in Prepare: %v", err)
+		}
+		if len(prepTable) != wantSizes[sourceID] {
+			t.Errorf("Expected %d rows for %s, got %d", wantSizes[sourceID], sourceID, len(prepTable))
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	joinedTablesPlain := IntersectPlain(tables, sourceIDs)
+	if !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+
+	if _, err := NewDataSource(sess, WithPadding(PadToSize(ROW_AMOUNT-1))).Prepare(tables["ds1"]); err == nil {
+		t.Errorf("Expected an error when the table exceeds the padding size")
+	}
+}
+
+func TestPadToPowerOfTwo(t *testing.T) {
+	for n, want := range map[int]int{0: 1, 1: 1, 2: 2, 3: 4, 8: 8, 9: 16, 1000: 1024} {
+		if got, _ := PadToPowerOfTwo()(n); got != want {
+			t.Errorf("PadToPowerOfTwo()(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestMPPJThreshold(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3", "ds4", "ds5"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithThreshold(3))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	// "all" is in all the sources, "three" in three of them, "two" in two of them.
+	tables := map[PartyID]TablePlain{
+		"ds1": {"all": "1a", "three": "1b", "two": "1c", "ds1": "1d"},
+		"ds2": {"all": "2a", "ds2": "2d"},
+		"ds3": {"all": "3a", "three": "3b", "ds3": "3d"},
+		"ds4": {"all": "4a", "two": "4c", "ds4": "4d"},
+		"ds5": {"all": "5a", "three": "5b", "ds5": "5d"},
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		if err != nil {
+			t.Fatalf("Error in Prepare: %v", err)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	joinedTablesPlain := IntersectPlainThreshold(tables, sourceIDs, 3)
+	if joinedTablesPlain.Len() != 2 || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+}
+
+func TestThresholdShares(t *testing.T) {
+	h := &Helper{}
+	shares, padKey := h.genShares(5, 3)
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}} {
+		xs := make([]*scalar, len(subset))
+		for j, i := range subset {
+			xs[j] = newScalar(big.NewInt(int64(i + 1)))
+		}
+		rec := newScalar(big.NewInt(0))
+		for j, i := range subset {
+			rec = rec.add(shares[i].mul(lagrangeCoefficient(xs, j)))
+		}
+		if !rec.Equals(padKey) {
+			t.Errorf("Failed to reconstruct the pad key from the shares %v", subset)
+		}
+	}
+}
+
+func TestMPPJLeftJoin(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithAnchor("ds1"))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	tables := map[PartyID]TablePlain{
+		"ds1": {"all": "1a", "ds2": "1b", "ds3": "1c", "none": "1d"},
+		"ds2": {"all": "2a", "ds2": "2b", "notanchor": "2e"},
+		"ds3": {"all": "3a", "ds3": "3c", "notanchor": "3e"},
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		ds := NewDataSource(sess, WithPadding(PadToSize(8))) // the anchor's dummy rows must not be in the join
+		prepTable, err := ds.Prepare(table)
+		if err != nil {
+			t.Fatalf("Error in Prepare: %v", err)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	if err != nil {
+		t.Fatalf("Error in Convert: %v", err)
+	}
+
+	intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+	if err != nil {
+		t.Fatalf("Error in JoinTables: %v", err)
+	}
+
+	joinedTablesPlain := IntersectPlainLeft(tables, sourceIDs, "ds1")
+	if joinedTablesPlain.Len() != 4 || !joinedTablesPlain.EqualContents(&intersectionMPPJ) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", joinedTablesPlain, intersectionMPPJ)
+	}
+
+	nulls := 0
+	for i := range intersectionMPPJ.Len() {
+		for j := range intersectionMPPJ.Columns() {
+			if _, ok := intersectionMPPJ.Cell(i, j); !ok {
+				nulls++
+			}
+		}
+	}
+	if nulls != 4 {
+		t.Errorf("Expected 4 null cells, got %d", nulls)
+	}
+
+	if _, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithAnchor("ds1"), WithThreshold(2)); err == nil {
+		t.Errorf("Expected an error when combining threshold and left joins")
+	}
+}
+
+func TestMPPJDuplicateUIDs(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+
+	// "one" has a single row per source, "many" has several rows in ds1 and ds3, "partial" is
+	// missing from ds2 but has as many rows as there are sources.
+	tables := map[PartyID]MultiTablePlain{
+		"ds1": {Columns: []string{"v"}, Rows: []Row{
+			{UID: "one", Values: []string{"1a"}}, {UID: "many", Values: []string{"1b"}}, {UID: "many", Values: []string{"1c"}}, {UID: "partial", Values: []string{"1d"}}, {UID: "partial", Values: []string{"1e"}},
+		}},
+		"ds2": {Columns: []string{"v"}, Rows: []Row{
+			{UID: "one", Values: []string{"2a"}}, {UID: "many", Values: []string{"2b"}},
+		}},
+		"ds3": {Columns: []string{"v"}, Rows: []Row{
+			{UID: "one", Values: []string{"3a"}}, {UID: "many", Values: []string{"3b"}}, {UID: "many", Values: []string{"3c"}}, {UID: "many", Values: []string{"3d"}}, {UID: "partial", Values: []string{"3e"}},
+		}},
+	}
+
+	for _, test := range []struct {
+		opt      SessionOption
+		expected JoinTable
+	}{
+		{nil, IntersectPlainMulti(tables, sourceIDs)},
+		{WithThreshold(2), IntersectPlainMultiThreshold(tables, sourceIDs, 2)},
+		{WithAnchor("ds1"), IntersectPlainMultiLeft(tables, sourceIDs, "ds1")},
+	} {
+		opts := []SessionOption{WithColumns("ds1", "v"), WithColumns("ds2", "v"), WithColumns("ds3", "v")}
+		if test.opt != nil {
+			opts = append(opts, test.opt)
+		}
+		rsk, rpk := KeyGen()
+		sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+
+		helper := NewHelper(sess)
+		receiver := NewReceiver(sess, rsk)
+		ds := NewDataSource(sess)
+
+		encTables := make(map[PartyID]EncTable, len(tables))
+		for sourceID, table := range tables {
+			prepTable, err := ds.PrepareMulti(table)
+			if err != nil {
+				t.Fatalf("Error in PrepareMulti: %v", err)
+			}
+			encTables[sourceID] = prepTable
+		}
+
+		joinedTables, err := helper.Convert(encTables)
+		if err != nil {
+			t.Fatalf("Error in Convert: %v", err)
+		}
+
+		intersectionMPPJ, err := receiver.JoinTables(joinedTables)
+		if err != nil {
+			t.Fatalf("Error in JoinTables: %v", err)
+		}
+
+		// 1 row for "one", 2*1*3 rows for "many", and 2*1 rows for "partial" in threshold and left joins
+		wantLen := 7
+		if test.opt != nil {
+			wantLen = 9
+		}
+		if test.expected.Len() != wantLen || !test.expected.EqualContents(&intersectionMPPJ) {
+			t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", test.expected, intersectionMPPJ)
+		}
+
+		// a row of the cross product duplicated in place of another has the wrong multiplicity
+		i := slices.IndexFunc(intersectionMPPJ.values, func(row []string) bool {
+			return !slices.Equal(row, intersectionMPPJ.values[0])
+		})
+		wrong := intersectionMPPJ
+		wrong.values, wrong.nulls = slices.Clone(wrong.values), slices.Clone(wrong.nulls)
+		wrong.values[i], wrong.nulls[i] = wrong.values[0], wrong.nulls[0]
+		if test.expected.EqualContents(&wrong) {
+			t.Errorf("Expected the tables with a duplicated row to differ: \n Plain: \n%s \n Wrong: \n%s", test.expected, wrong)
+		}
+	}
+}
+
+func TestMPPJCardinality(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c", " A": "1A"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d", "e": "3e"},
+	}
+
+	for _, test := range []struct {
+		name      string
+		threshold int
+		expected  int
+	}{
+		{"all", 0, 1},
+		{"threshold", 2, 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rsk, rpk := KeyGen()
+			opts := []SessionOption{WithCardinalityOnly(), WithNormalization(TrimSpace(), CaseFold())}
+			if test.threshold > 0 {
+				opts = append(opts, WithThreshold(test.threshold))
+			}
+			sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+
+			helper := NewHelper(sess)
+			receiver := NewReceiver(sess, rsk)
+			ds := NewDataSource(sess, WithPadding(PadToSize(8)))
+
+			encTables := make(map[PartyID]EncTable, len(tables))
+			for sourceID, table := range tables {
+				prepTable, err := ds.Prepare(table)
+				if err != nil {
+					t.Fatalf("Error in Prepare: %v", err)
+				}
+				for _, row := range prepTable {
+					if len(row.Cval) != 0 {
+						t.Fatalf("Expected rows without values")
+					}
+				}
+				encTables[sourceID] = prepTable
+			}
+
+			joinedTables, err := helper.Convert(encTables)
+			if err != nil {
+				t.Fatalf("Error in Convert: %v", err)
+			}
+
+			// the rows without values go through the wire format
+			for i, row := range joinedTables {
+				data, err := row.MarshalBinary()
+				if err != nil {
+					t.Fatalf("Error in MarshalBinary: %v", err)
+				}
+				if err := joinedTables[i].UnmarshalBinary(data); err != nil {
+					t.Fatalf("Error in UnmarshalBinary: %v", err)
+				}
+			}
+
+			count, err := receiver.CountTables(joinedTables)
+			if err != nil {
+				t.Fatalf("Error in CountTables: %v", err)
+			}
+			if count != test.expected {
+				t.Errorf("Expected a join size of %d, got %d", test.expected, count)
+			}
+
+			if _, err := receiver.JoinTables(joinedTables); err == nil {
+				t.Errorf("Expected an error when joining in a cardinality-only session")
+			}
+		})
+	}
+}
+
+func TestMPPJTamperedValues(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2"}
+	rsk, rpk := KeyGen()
+
+	for _, test := range []struct {
+		name string
+		opts []SessionOption
+	}{
+		{"all", nil},
+		{"threshold", []SessionOption{WithThreshold(2)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, test.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+			helper := NewHelper(sess)
+			receiver := NewReceiver(sess, rsk)
+			ds := NewDataSource(sess)
+
+			encTables := make(map[PartyID]EncTable, len(sourceIDs))
+			for _, sourceID := range sourceIDs {
+				prepTable, err := ds.Prepare(TablePlain{"a": "val " + string(sourceID)})
+				if err != nil {
+					t.Fatalf("Error in Prepare: %v", err)
+				}
+				encTables[sourceID] = prepTable
+			}
+			joinedTables, err := helper.Convert(encTables)
+			if err != nil {
+				t.Fatalf("Error in Convert: %v", err)
+			}
+
+			tampered := slices.Clone(joinedTables)
+			tampered[0].CVal = slices.Clone(tampered[0].CVal)
+			tampered[0].CVal[0] ^= 1
+			_, err = receiver.JoinTables(tampered)
+			var rowErr *RowError
+			if !errors.Is(err, ErrAuthentication) || !errors.As(err, &rowErr) || rowErr.Row != 0 {
+				t.Errorf("Expected an authentication error identifying the tampered row, got %v", err)
+			}
+
+			// the values of a row cannot be moved to another session
+			other, err := NewSession(sourceIDs, "helper", "receiver", rpk, test.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+			if _, err := NewReceiver(other, rsk).JoinTables(joinedTables); err == nil {
+				t.Errorf("Expected an error when joining the rows of another session")
+			}
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2"}
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithKeySchema("first", "last"))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess, WithSourceID("ds1"))
+
+	// a row failing in a worker is reported, without blocking the stream
+	table := MultiTablePlain{Columns: []string{"v"}, Rows: []Row{
+		{UID: CompositeKey{"Alice", "Smith"}.UID(), Values: []string{"a"}},
+		{UID: "not a composite key", Values: []string{"b"}},
+		{UID: CompositeKey{"Bob", "Jones"}.UID(), Values: []string{"c"}},
+	}}
+	encRows, errc := ds.PrepareMultiStream(context.Background(), table, 1)
+	for range encRows {
+	}
+	var rowErr *RowError
+	if err := <-errc; !errors.As(err, &rowErr) || rowErr.Row != 1 || rowErr.Source != "ds1" {
+		t.Errorf("Expected an error for row 1 of ds1, got %v", err)
+	}
+
+	// the streams stop on cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	encRows, errc = ds.PrepareStream(ctx, TablePlain{CompositeKey{"Alice", "Smith"}.UID(): "a"})
+	for range encRows {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+	if _, err := receiver.JoinTablesStream(ctx, make(chan EncRowWithHint)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+	if _, err := helper.ConvertStream(ctx, rpk, make(chan ConvertRowTask)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+
+	// the helper reports the failing row of a source
+	encTable, err := ds.PrepareMulti(MultiTablePlain{Columns: []string{"v"}, Rows: table.Rows[:1]})
+	if err != nil {
+		t.Fatalf("Error in PrepareMulti: %v", err)
+	}
+	tasks := make(chan ConvertRowTask, 3)
+	tasks <- ConvertRowTask{EncRowMsg: encTable[0], SourceID: "ds2"}
+	tasks <- ConvertRowTask{EncRowMsg: encTable[0], SourceID: "ds1"}
+	tasks <- ConvertRowTask{EncRowMsg: EncRow{Cuid: encTable[0].Cuid}, SourceID: "ds2"}
+	close(tasks)
+	if _, err := helper.ConvertStream(context.Background(), rpk, tasks, 1); !errors.As(err, &rowErr) || rowErr.Row != 1 || rowErr.Source != "ds2" {
+		t.Errorf("Expected an error for row 1 of ds2, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package mppj
+
+import (
+	circl "github.com/cloudflare/circl/group"
+)
+
+// multiExpWindow is the width in bits of the scalar digits of the multi-exponentiations.
+const multiExpWindow = 4
+
+// The multi-exponentiations compute the product of the bases raised to their exponents with Straus' method:
+// the multiples base^1, ..., base^15 of each base are precomputed, and the digits of all the exponents are
+// processed together from the most significant one, so that the doublings are shared by all the bases. They
+// are not constant time, and must only be used on public values, as in the verification of proofs.
+
+// scalarDigits appends to dst the digits of s of multiExpWindow bits, from the most significant one.
+func scalarDigits(dst []byte, s *scalar) []byte {
+	sb, _ := s.s.MarshalBinary()
+	for i := range sb {
+		b := sb[i]
+		if scalarLittleEndian() {
+			b = sb[len(sb)-1-i]
+		}
+		dst = append(dst, b>>multiExpWindow, b&(1<<multiExpWindow-1))
+	}
+	return dst
+}
+
+// circlMultiExp returns the product of the bases raised to the exponents, with the group operations of circl.
+func circlMultiExp(bases []*point, exps []*scalar) *point {
+	tables := make([][1<<multiExpWindow - 1]circl.Element, len(bases))
+	digits := make([][]byte, len(bases))
+	for i, base := range bases {
+		tables[i][0] = base.p.Copy()
+		for j := 1; j < len(tables[i]); j++ {
+			tables[i][j] = group.NewElement().Add(tables[i][j-1], base.p)
+		}
+		digits[i] = scalarDigits(nil, exps[i])
+	}
+
+	acc := group.Identity()
+	for d := range 2 * int(group.Params().ScalarLength) {
+		if d > 0 {
+			for range multiExpWindow {
+				acc.Dbl(acc)
+			}
+		}
+		for i := range bases {
+			if digit := digits[i][d]; digit != 0 {
+				acc.Add(acc, tables[i][digit-1])
+			}
+		}
+	}
+	return &point{p: acc}
+}
@@ -0,0 +1,143 @@
+package mppj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer is a deterministic transformation of the join keys, applied by the sources before hashing.
+// Its name identifies the transformation and its parameters, and is bound into the session ID.
+type Normalizer interface {
+	Name() string
+	Normalize(key string) (string, error)
+}
+
+type normalizer struct {
+	name string
+	f    func(string) (string, error)
+}
+
+func (n normalizer) Name() string {
+	return n.name
+}
+
+func (n normalizer) Normalize(key string) (string, error) {
+	return n.f(key)
+}
+
+// NewNormalizer returns a custom Normalizer with the given name, which must uniquely identify f.
+func NewNormalizer(name string, f func(string) (string, error)) Normalizer {
+	return normalizer{name: name, f: f}
+}
+
+// TrimSpace removes the leading and trailing white space of the keys.
+func TrimSpace() Normalizer {
+	return NewNormalizer("trim", func(key string) (string, error) {
+		return strings.TrimSpace(key), nil
+	})
+}
+
+// UnicodeNFC puts the keys in Unicode Normalization Form C.
+func UnicodeNFC() Normalizer {
+	return NewNormalizer("nfc", func(key string) (string, error) {
+		return norm.NFC.String(key), nil
+	})
+}
+
+// CaseFold applies Unicode case folding to the keys.
+func CaseFold() Normalizer {
+	return NewNormalizer("casefold", func(key string) (string, error) {
+		return cases.Fold().String(key), nil
+	})
+}
+
+// E164 formats phone number keys as E.164 numbers, such as +41446681800. Numbers not starting with + or 00
+// are considered national numbers of the given country calling code, and their trunk prefix 0 is removed.
+func E164(countryCode string) Normalizer {
+	return NewNormalizer("e164:"+countryCode, func(key string) (string, error) {
+		number := strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '-', '.', '(', ')', '/':
+				return -1
+			}
+			return r
+		}, key)
+
+		switch {
+		case strings.HasPrefix(number, "+"):
+			number = number[1:]
+		case strings.HasPrefix(number, "00"):
+			number = number[2:]
+		default:
+			number = countryCode + strings.TrimPrefix(number, "0")
+		}
+
+		if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+			return "", fmt.Errorf("invalid phone number length or country code")
+		}
+		for _, r := range number {
+			if r < '0' || r > '9' {
+				return "", fmt.Errorf("invalid character in phone number")
+			}
+		}
+		return "+" + number, nil
+	})
+}
+
+// CanonicalEmail canonicalizes email address keys: it lower-cases the address and removes the sub-address
+// (the part of the local part after a +). For Gmail addresses, it also removes the dots of the local part,
+// and maps googlemail.com to gmail.com.
+func CanonicalEmail() Normalizer {
+	return NewNormalizer("email", func(key string) (string, error) {
+		at := strings.LastIndexByte(key, '@')
+		if at <= 0 || at == len(key)-1 {
+			return "", fmt.Errorf("invalid email address")
+		}
+		local, domain := strings.ToLower(key[:at]), strings.ToLower(key[at+1:])
+		if plus := strings.IndexByte(local, '+'); plus >= 0 {
+			local = local[:plus]
+		}
+		if domain == "gmail.com" || domain == "googlemail.com" {
+			local, domain = strings.ReplaceAll(local, ".", ""), "gmail.com"
+		}
+		if local == "" {
+			return "", fmt.Errorf("invalid email address")
+		}
+		return local + "@" + domain, nil
+	})
+}
+
+// SHA256Prehash replaces the keys by the hex encoding of their SHA-256 digest.
+func SHA256Prehash() Normalizer {
+	return NewNormalizer("sha256", func(key string) (string, error) {
+		digest := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(digest[:]), nil
+	})
+}
+
+// NormalizationDigest returns the SHA-256 digest of a normalization ruleset, computed over the rules' names.
+func NormalizationDigest(rules []Normalizer) []byte {
+	names := make(CompositeKey, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name()
+	}
+	digest := sha256.Sum256(names.Encode())
+	return digest[:]
+}
+
+// normalize applies the rules to the key, in order.
+func normalize(rules []Normalizer, key string) (string, error) {
+	var err error
+	for _, rule := range rules {
+		key, err = rule.Normalize(key)
+		if err != nil {
+			return "", fmt.Errorf("normalization %s failed: %w", rule.Name(), err)
+		}
+	}
+	return key, nil
+}
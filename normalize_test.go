@@ -0,0 +1,134 @@
+package mppj
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		rule    Normalizer
+		in, out string
+		err     bool
+	}{
+		{TrimSpace(), "  Alice@Example.com \t", "Alice@Example.com", false},
+		{UnicodeNFC(), "Zoé", "Zoé", false},
+		{CaseFold(), "STRASSE Straße", "strasse strasse", false},
+		{E164("41"), "+41 44 668 18 00", "+41446681800", false},
+		{E164("41"), "0041 (44) 668-18-00", "+41446681800", false},
+		{E164("41"), "044 668 18 00", "+41446681800", false},
+		{E164("41"), "044 668 18 0x", "", true},
+		{E164("41"), "123", "", true},
+		{CanonicalEmail(), "Alice+news@Example.com", "alice@example.com", false},
+		{CanonicalEmail(), "A.Lice+x@GoogleMail.com", "alice@gmail.com", false},
+		{CanonicalEmail(), "a.lice@example.com", "a.lice@example.com", false},
+		{CanonicalEmail(), "alice.example.com", "", true},
+		{CanonicalEmail(), "+tag@example.com", "", true},
+		{SHA256Prehash(), "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", false},
+	}
+
+	for _, test := range tests {
+		out, err := test.rule.Normalize(test.in)
+		if test.err {
+			require.Error(t, err, "%s(%q)", test.rule.Name(), test.in)
+			continue
+		}
+		require.NoError(t, err, "%s(%q)", test.rule.Name(), test.in)
+		require.Equal(t, test.out, out, "%s(%q)", test.rule.Name(), test.in)
+	}
+
+	if bytes.Equal(NormalizationDigest([]Normalizer{TrimSpace(), CaseFold()}), NormalizationDigest([]Normalizer{CaseFold(), TrimSpace()})) {
+		t.Errorf("Expected the digest to depend on the order of the normalizers")
+	}
+	if bytes.Equal(NormalizationDigest([]Normalizer{E164("41")}), NormalizationDigest([]Normalizer{E164("33")})) {
+		t.Errorf("Expected the digest to depend on the normalizers' parameters")
+	}
+}
+
+func TestMPPJNormalization(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2"}
+	rsk, rpk := KeyGen()
+	sid := NewSessionID(sourceIDs, "helper", "receiver")
+	rules := []Normalizer{TrimSpace(), UnicodeNFC(), CanonicalEmail()}
+	sess, err := NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithNormalization(rules...))
+	require.NoError(t, err, "NewSessionWithID() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	tables := map[PartyID]TablePlain{
+		"ds1": {"Alice@Example.com ": "a1", "bob@example.com": "b1", "carol@example.com": "c1"},
+		"ds2": {"alice@example.com": "a2", " Bob+shop@EXAMPLE.com": "b2", "dave@example.com": "d2"},
+	}
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	require.NoError(t, err, "Convert() error")
+
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+
+	expected := NewJoinTable(sourceIDs)
+	expected.Insert(map[PartyID]string{"ds1": "a1", "ds2": "a2"})
+	expected.Insert(map[PartyID]string{"ds1": "b1", "ds2": "b2"})
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected the normalized keys to match: \n Expected: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+
+	if _, err := ds.ProcessRow("not an email", "v"); err == nil {
+		t.Errorf("Expected an error for a key rejected by the normalization")
+	}
+
+	// a source with a different ruleset ends up in a different session, which the helper detects
+	other, err := NewSessionWithID(sid, sourceIDs, "helper", "receiver", rpk, WithNormalization(TrimSpace(), CanonicalEmail()))
+	require.NoError(t, err, "NewSessionWithID() error")
+	require.NoError(t, helper.CheckSessionID(ds.SessionID()))
+	require.Error(t, helper.CheckSessionID(NewDataSource(other).SessionID()))
+
+	md, _ := metadata.FromOutgoingContext(SessionIDToOutgoingContext(context.Background(), ds.SessionID()))
+	claimed, ok := SessionIDFromIncomingContext(metadata.NewIncomingContext(context.Background(), md))
+	require.True(t, ok)
+	require.NoError(t, helper.CheckSessionID(claimed))
+
+	// the helper rejects the rows of the other session
+	otherTable, err := NewDataSource(other).Prepare(tables["ds2"])
+	require.NoError(t, err, "Prepare() error")
+	_, err = helper.Convert(map[PartyID]EncTable{"ds1": encTables["ds1"], "ds2": otherTable})
+	require.Error(t, err, "expected an error for the rows of another session")
+
+	// as well as the deserialized rows, which carry the tag of their session, with or without a claim
+	data, err := otherTable[0].MarshalBinary()
+	require.NoError(t, err)
+	var row EncRow
+	require.NoError(t, row.UnmarshalBinary(data))
+	for _, claim := range []SessionID{nil, NewDataSource(other).SessionID(), ds.SessionID()} {
+		tasks := make(chan ConvertRowTask, 1)
+		tasks <- ConvertRowTask{EncRowMsg: row, SourceID: "ds2", SessionID: claim}
+		close(tasks)
+		_, err = helper.ConvertStream(context.Background(), rpk, tasks)
+		var rowErr *RowError
+		require.ErrorAs(t, err, &rowErr)
+		require.ErrorIs(t, err, errSessionMismatch)
+	}
+
+	// and the rows of this session claiming another one, or not prepared by a data source
+	_, err = helper.ConvertRow(rpk, &EncRow{Cuid: encTables["ds1"][0].Cuid}, "ds1")
+	require.ErrorIs(t, err, errMissingSession)
+	tasks := make(chan ConvertRowTask, 1)
+	tasks <- ConvertRowTask{EncRowMsg: encTables["ds1"][0], SourceID: "ds1", SessionID: NewDataSource(other).SessionID()}
+	close(tasks)
+	_, err = helper.ConvertStream(context.Background(), rpk, tasks)
+	require.ErrorIs(t, err, errSessionMismatch)
+}
@@ -1,9 +1,17 @@
 package mppj
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"math/rand/v2"
-	"runtime"
+	"io"
+	"math/bits"
+	"os"
+	"slices"
 	"sync"
 )
 
@@ -12,89 +20,731 @@ import (
 type DataSource struct {
 	sid []byte
 	rpk PublicKey
+	id  PartyID
+
+	keySchema     []string
+	keyPrefix     []byte
+	normalization []Normalizer
+
+	cardinalityOnly bool
+	proofs          bool
+	signingKey      ed25519.PrivateKey
+
+	padding Padding
+
+	// uidPool and valuePool hold the precomputed encryption randomness under the receiver keys, see
+	// [DataSource.Precompute].
+	uidPool, valuePool *randomnessPool
+
+	// shuffleDir and shuffleBuckets configure the external shuffle of [DataSource.PrepareRowStream].
+	shuffleDir     string
+	shuffleBuckets int
+}
+
+// DataSourceOption is an optional parameter of a DataSource.
+type DataSourceOption func(*DataSource)
+
+// Padding determines the number of rows output by a source holding a table of n rows. The difference
+// is filled with dummy rows, which are indistinguishable from real rows for the helper, and never match
+// in the join.
+type Padding func(n int) (int, error)
+
+// PadToPowerOfTwo pads tables to the next power of two.
+func PadToPowerOfTwo() Padding {
+	return func(n int) (int, error) {
+		if n <= 1 {
+			return 1, nil
+		}
+		return 1 << bits.Len(uint(n-1)), nil
+	}
+}
+
+// PadToSize pads tables to a fixed number of rows. Larger tables are rejected.
+func PadToSize(size int) Padding {
+	return func(n int) (int, error) {
+		if n > size {
+			return 0, fmt.Errorf("table has %d rows, more than the padding size %d", n, size)
+		}
+		return size, nil
+	}
+}
+
+// WithPadding makes the data source pad its prepared tables with dummy rows according to p.
+func WithPadding(p Padding) DataSourceOption {
+	return func(s *DataSource) {
+		s.padding = p
+	}
+}
+
+// WithSourceID sets the PartyID of the data source, to which its proofs are bound in sessions with source proofs.
+func WithSourceID(id PartyID) DataSourceOption {
+	return func(s *DataSource) {
+		s.id = id
+	}
+}
+
+// WithSigningKey sets the long-term Ed25519 signing key of the data source, with which it signs its uploads
+// in sessions with registered source keys. The data source must also know its ID, see [WithSourceID].
+func WithSigningKey(sk ed25519.PrivateKey) DataSourceOption {
+	return func(s *DataSource) {
+		s.signingKey = sk
+	}
+}
+
+// WithShuffleBuckets configures the external shuffle of [DataSource.PrepareRowStream], which writes the
+// encrypted rows to the given number of buckets on disk. The temporary files are created in a new directory
+// inside dir, or inside the default directory for temporary files if dir is empty. The number of buckets should
+// be large enough for a bucket to fit in memory, and a number smaller than one selects a default of 256 buckets.
+func WithShuffleBuckets(dir string, buckets int) DataSourceOption {
+	return func(s *DataSource) {
+		s.shuffleDir, s.shuffleBuckets = dir, buckets
+		if buckets < 1 {
+			s.shuffleBuckets = defaultSpillBuckets
+		}
+	}
 }
 
 // NewDataSource creates a new DataSource for the given session.
-func NewDataSource(sess *Session) *DataSource {
-	return &DataSource{sid: sess.ID, rpk: sess.ReceiverPK}
+func NewDataSource(sess *Session, opts ...DataSourceOption) *DataSource {
+	s := &DataSource{sid: sess.ID, rpk: sess.ReceiverPK, keySchema: sess.KeySchema, normalization: sess.Normalization, cardinalityOnly: sess.CardinalityOnly, proofs: sess.SourceProofs}
+	s.uidPool, s.valuePool = newRandomnessPool(s.rpk.bpk), newRandomnessPool(s.rpk.epk)
+	s.shuffleBuckets = defaultSpillBuckets
+	if len(s.keySchema) > 0 {
+		s.keyPrefix = CompositeKey(s.keySchema).Encode() // sources with different key schemas never match
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Prepare prepares a table for joining by adding hashing the UIDs and encrypting its contents towards the receiver.
 func (s *DataSource) Prepare(table TablePlain) (EncTable, error) {
+	return s.prepare(table.Rows(), 1)
+}
 
-	preparedTable := make(EncTable, len(table))
+// PrepareStream is the streaming version of [Prepare]. It sends encrypted rows through the encRows channel,
+// as they are processed. It is optionally possible to specify the number of goroutines workers to use.
+// The workers stop on the cancellation of ctx or on the first error, which is then sent through the errc
+// channel, as a [*RowError] for the errors of individual rows. The errc channel is closed after encRows.
+func (s *DataSource) PrepareStream(ctx context.Context, table TablePlain, goroutines ...int) (encRows <-chan EncRow, errc <-chan error) {
+	return s.prepareStream(ctx, table.Rows(), 1, goroutines...)
+}
 
-	encRows, err := s.PrepareStream(table)
-	if err != nil {
+// PrepareMulti is the multi-column version of [Prepare]. Each column of the table is encrypted separately.
+func (s *DataSource) PrepareMulti(table MultiTablePlain) (EncTable, error) {
+	if err := table.Validate(); err != nil {
 		return nil, err
 	}
+	return s.prepare(table.Rows, len(table.Columns))
+}
+
+// PrepareMultiStream is the streaming version of [PrepareMulti].
+func (s *DataSource) PrepareMultiStream(ctx context.Context, table MultiTablePlain, goroutines ...int) (encRows <-chan EncRow, errc <-chan error) {
+	if err := table.Validate(); err != nil {
+		return failedStream(err)
+	}
+	return s.prepareStream(ctx, table.Rows, len(table.Columns), goroutines...)
+}
+
+// PrepareRowStream is the variant of [DataSource.PrepareStream] for tables larger than the source's memory, which
+// are read from rows, with numColumns values per row. The rows are encrypted as they are read and written to
+// random buckets on disk, see [WithShuffleBuckets], which are then shuffled one at a time and sent through the
+// encRows channel, so that the output order does not depend on the order of rows. In cardinality-only
+// sessions, the bucket of a row is derived from a keyed hash of its normalized UID instead, so that the UIDs
+// are contributed once, as with [DataSource.Prepare]. The dummy values of the padding have the lengths of the
+// values of rows sampled from a bounded reservoir.
+//
+// The errors are reported as by PrepareStream, with the position of the row in rows. The temporary files hold
+// encrypted rows only, and are removed before errc is closed.
+func (s *DataSource) PrepareRowStream(ctx context.Context, rows RowReader, numColumns int, goroutines ...int) (encRows <-chan EncRow, errc <-chan error) {
+	out, errs := make(chan EncRow), make(chan error, 1)
+	go func() {
+		err := s.prepareExternal(ctx, rows, numColumns, out, numWorkers(goroutines))
+		close(out)
+		if err != nil {
+			errs <- err
+		}
+		close(errs)
+	}()
+	return out, errs
+}
+
+// dummyReservoirSize is the number of rows sampled by PrepareRowStream for the lengths of the dummy values.
+const dummyReservoirSize = 1024
+
+// uidTagSize is the size of the keyed hashes of the UIDs of PrepareRowStream in cardinality-only sessions.
+const uidTagSize = sha256.Size
+
+// prepareExternal encrypts the rows of rows with n workers, which write them to buckets along with a tag in
+// cardinality-only sessions, and then sends the buckets to out with the dummy rows of the padding. The rows
+// and the dummy rows are assigned to the buckets independently and uniformly at random, so that shuffling
+// each bucket and concatenating the buckets results in a uniformly random permutation. In cardinality-only
+// sessions, the rows with the same UID have the same tag and bucket, in which only the first one is kept.
+func (s *DataSource) prepareExternal(ctx context.Context, rows RowReader, numColumns int, out chan<- EncRow, n int) error {
+	if s.proofs && s.id == "" {
+		return fmt.Errorf("source ID required in sessions with source proofs")
+	}
+	if s.cardinalityOnly {
+		numColumns = 0
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	buckets, err := newSpillBuckets(s.shuffleDir, s.shuffleBuckets)
+	if err != nil {
+		return fmt.Errorf("creating the buckets of the external shuffle: %w", err)
+	}
+	defer buckets.remove()
+
+	rng := secureRand()
+	var tagKey []byte
+	if s.cardinalityOnly {
+		tagKey = make([]byte, 32)
+		for j := range tagKey {
+			tagKey[j] = byte(rng.Uint32())
+		}
+	}
+
+	type rowTask struct {
+		Row
+		i int
+	}
+
+	// spill encrypts the rows of tasks with n workers, and writes them to the buckets
+	spill := func(tasks chan rowTask) {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rng := secureRand()
+				for task := range tasks {
+					if !task.dummy && len(task.Values) != numColumns && !s.cardinalityOnly {
+						cancel(&RowError{Source: s.id, Row: task.i, Err: fmt.Errorf("row has %d values, expected %d", len(task.Values), numColumns)})
+						return
+					}
+
+					var record []byte
+					bucket := rng.IntN(buckets.len())
+					if s.cardinalityOnly {
+						record = make([]byte, uidTagSize)
+						if task.dummy {
+							for j := range record { // never equal to the tag of a row
+								record[j] = byte(rng.Uint32())
+							}
+						} else {
+							msg, err := s.oprfInput(task.UID)
+							if err != nil {
+								cancel(&RowError{Source: s.id, Row: task.i, Err: err})
+								return
+							}
+							mac := hmac.New(sha256.New, tagKey)
+							mac.Write(msg)
+							record = mac.Sum(record[:0])
+							bucket = int(binary.BigEndian.Uint32(record) % uint32(buckets.len()))
+						}
+						task.Values = nil
+					}
+
+					var encRow EncRow
+					var err error
+					if task.dummy {
+						encRow, err = s.processDummyRow(task.Values...)
+					} else {
+						encRow, err = s.ProcessRowWithProof(task.UID, task.Values...)
+					}
+					var data []byte
+					if err == nil {
+						data, err = encRow.MarshalBinary()
+					}
+					if err == nil {
+						err = buckets.write(bucket, append(record, data...))
+					}
+					if err != nil {
+						cancel(&RowError{Source: s.id, Row: task.i, Err: err})
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// the reader samples the value lengths of the rows for the dummy rows
+	var reservoir [][]int
+	count := 0
+	tasks := make(chan rowTask)
+	go func() {
+		defer close(tasks)
+		for ; ; count++ {
+			row, err := rows.ReadRow()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				cancel(&RowError{Source: s.id, Row: count, Err: err})
+				return
+			}
+			lengths := make([]int, len(row.Values))
+			for j, val := range row.Values {
+				lengths[j] = len(val)
+			}
+			if len(reservoir) < dummyReservoirSize {
+				reservoir = append(reservoir, lengths)
+			} else if j := rng.IntN(count + 1); j < dummyReservoirSize {
+				reservoir[j] = lengths
+			}
+			select {
+			case tasks <- rowTask{Row: Row{UID: row.UID, Values: row.Values}, i: count}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	spill(tasks)
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	if err := buckets.flush(); err != nil {
+		return fmt.Errorf("writing to the buckets of the external shuffle: %w", err)
+	}
+
+	// forEach calls f on the encrypted rows of bucket i, skipping the repeated tags in cardinality-only sessions
+	forEach := func(i int, f func(data []byte) error) error {
+		seen := make(map[string]struct{})
+		return buckets.read(i, func(record []byte) error {
+			if s.cardinalityOnly {
+				if len(record) < uidTagSize {
+					return fmt.Errorf("invalid record in bucket %d", i)
+				}
+				if _, ok := seen[string(record[:uidTagSize])]; ok {
+					return nil
+				}
+				seen[string(record[:uidTagSize])] = struct{}{}
+				record = record[uidTagSize:]
+			}
+			return f(record)
+		})
+	}
+
+	if s.cardinalityOnly { // the distinct UIDs
+		count = 0
+		for i := range buckets.len() {
+			if err := forEach(i, func([]byte) error { count++; return nil }); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.padding != nil {
+		size, err := s.padding(count)
+		if err != nil {
+			return err
+		}
+		if size < count {
+			return fmt.Errorf("padding size %d is smaller than the table size %d", size, count)
+		}
+		dummies := make(chan rowTask)
+		go func() {
+			defer close(dummies)
+			for range size - count {
+				dummy := Row{Values: make([]string, numColumns), dummy: true}
+				if len(reservoir) > 0 {
+					model := reservoir[rng.IntN(len(reservoir))]
+					dummy.Values = make([]string, len(model))
+					for j, l := range model {
+						dummy.Values[j] = string(make([]byte, l))
+					}
+				}
+				select {
+				case dummies <- rowTask{Row: dummy}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		spill(dummies)
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+		if err := buckets.flush(); err != nil {
+			return fmt.Errorf("writing to the buckets of the external shuffle: %w", err)
+		}
+	}
+
+	for i := range buckets.len() {
+		var encRows EncTable
+		err := forEach(i, func(data []byte) error {
+			var encRow EncRow
+			if err := encRow.UnmarshalBinary(data); err != nil {
+				return err
+			}
+			encRows = append(encRows, encRow)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := buckets.discard(i); err != nil {
+			return err
+		}
+		rng.Shuffle(len(encRows), func(i, j int) {
+			encRows[i], encRows[j] = encRows[j], encRows[i]
+		})
+		for _, encRow := range encRows {
+			select {
+			case out <- encRow:
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+	}
+	return nil
+}
 
-	i := 0
+func (s *DataSource) prepare(table []Row, numColumns int) (EncTable, error) {
+
+	encRows, errc := s.prepareStream(context.Background(), table, numColumns)
+
+	preparedTable := make(EncTable, 0, len(table))
 	for encRow := range encRows {
-		preparedTable[i] = encRow
-		i++
+		preparedTable = append(preparedTable, encRow)
 	}
-	if i != len(table) {
-		return nil, fmt.Errorf("number of prepared elements do not match")
+	if err := <-errc; err != nil {
+		return nil, err
 	}
 
 	return preparedTable, nil
 }
 
-// PrepareStream is the streaming version of [Prepare]. It sends encrypted rows through the encRows channel,
-// as they are processed. It is optionally possible to specify the number of goroutines workers to use.
-func (s *DataSource) PrepareStream(table TablePlain, goroutines ...int) (encRows <-chan EncRow, err error) {
+// failedStream returns a closed stream of encrypted rows, along with the error that prevented it.
+func failedStream(err error) (<-chan EncRow, <-chan error) {
+	encRows, errc := make(chan EncRow), make(chan error, 1)
+	close(encRows)
+	errc <- err
+	close(errc)
+	return encRows, errc
+}
+
+// distinct returns, in cardinality-only sessions, the distinct UIDs of the table as rows without values.
+// As the receiver cannot tell the rows of the same source apart in these sessions, UIDs that are equal
+// after normalization are contributed once. In other sessions, it returns the table unchanged.
+func (s *DataSource) distinct(table []Row, numColumns int) ([]Row, int, error) {
+	if !s.cardinalityOnly {
+		return table, numColumns, nil
+	}
+	seen := make(map[string]struct{}, len(table))
+	uids := make([]Row, 0, len(table))
+	for i, row := range table {
+		msg, err := s.oprfInput(row.UID)
+		if err != nil {
+			return nil, 0, &RowError{Source: s.id, Row: i, Err: err}
+		}
+		if _, exists := seen[string(msg)]; exists {
+			continue
+		}
+		seen[string(msg)] = struct{}{}
+		uids = append(uids, Row{UID: row.UID})
+	}
+	return uids, 0, nil
+}
+
+// pad returns the table extended with dummy rows according to the data source's padding policy.
+// The dummy values have the lengths of randomly chosen real values.
+func (s *DataSource) pad(table []Row, numColumns int) ([]Row, error) {
+	if s.padding == nil {
+		return table, nil
+	}
+	size, err := s.padding(len(table))
+	if err != nil {
+		return nil, err
+	}
+	if size < len(table) {
+		return nil, fmt.Errorf("padding size %d is smaller than the table size %d", size, len(table))
+	}
+
+	padded := make([]Row, len(table), size)
+	copy(padded, table)
+	rng := secureRand()
+	for range size - len(table) {
+		dummy := Row{Values: make([]string, numColumns), dummy: true}
+		if len(table) > 0 {
+			model := table[rng.IntN(len(table))]
+			dummy.Values = make([]string, len(model.Values))
+			for i, val := range model.Values {
+				dummy.Values[i] = string(make([]byte, len(val)))
+			}
+		}
+		padded = append(padded, dummy)
+	}
+	return padded, nil
+}
+
+func (s *DataSource) prepareStream(ctx context.Context, table []Row, numColumns int, goroutines ...int) (encRows <-chan EncRow, errc <-chan error) {
+	if s.proofs && s.id == "" {
+		return failedStream(fmt.Errorf("source ID required in sessions with source proofs"))
+	}
+	table, numColumns, err := s.distinct(table, numColumns)
+	if err != nil {
+		return failedStream(err)
+	}
+	table, err = s.pad(table, numColumns)
+	if err != nil {
+		return failedStream(err)
+	}
+	return s.encryptStream(ctx, table, goroutines...)
+}
+
+// encryptStream encrypts the rows of table in a random order, and sends them through the encRows channel.
+// The errors of the rows are reported with the position of the row in table.
+func (s *DataSource) encryptStream(ctx context.Context, table []Row, goroutines ...int) (<-chan EncRow, <-chan error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
 	var wg sync.WaitGroup
 
-	rows := make(chan TableRow, len(table))
+	rows := make(chan int)
 
 	encRowsChan := make(chan EncRow, len(table))
-	//fmt.Printf("tasks: %d\n", len(table))
+	errc := make(chan error, 1)
 
-	n := runtime.NumCPU()
-	if len(goroutines) > 0 && goroutines[0] > 0 {
-		n = goroutines[0]
-	}
+	n := numWorkers(goroutines)
 
 	for range n {
 		wg.Add(1)
 		go func() {
+			defer wg.Done()
+			for i := range rows {
+				task := table[i]
 
-			i := 0
-			for task := range rows {
-
-				cuid, cval, err := s.ProcessRow(task.uid, task.val)
+				var encRow EncRow
+				var err error
+				if task.dummy {
+					encRow, err = s.processDummyRow(task.Values...)
+				} else {
+					encRow, err = s.ProcessRowWithProof(task.UID, task.Values...)
+				}
 				if err != nil {
+					cancel(&RowError{Source: s.id, Row: i, Err: err})
+					return
+				}
+				select {
+				case encRowsChan <- encRow:
+				case <-ctx.Done():
 					return
 				}
-				encRowsChan <- EncRow{Cuid: cuid, Cval: cval}
-				i++
 			}
-			//fmt.Printf("worker processed %d\n", i)
-			wg.Done()
 		}()
 	}
 
 	go func() {
-		uids := make([]string, 0, len(table))
-		for uid := range table {
-			uids = append(uids, uid)
-		}
-		perm := rand.Perm(len(uids)) // TODO: use secure random source
-		for _, uid := range perm {
-			rows <- TableRow{uid: uids[uid], val: table[uids[uid]]}
+		perm := secureRand().Perm(len(table))
+	feed:
+		for _, i := range perm {
+			select {
+			case rows <- i:
+			case <-ctx.Done():
+				break feed
+			}
 		}
 		close(rows)
 		wg.Wait()
 		close(encRowsChan)
+		if err := context.Cause(ctx); err != nil {
+			errc <- err
+		}
+		close(errc)
+		cancel(nil)
 	}()
 
-	return encRowsChan, nil
+	return encRowsChan, errc
 }
 
-// ProcessRow processes a single row, returning the encrypted UID and encrypted value.
-func (s *DataSource) ProcessRow(uid, val string) (cuid *Ciphertext, cval []*Ciphertext, err error) {
-	cuid = oprfBlind(s.rpk.bpk, []byte(uid), s.sid)
-	cval, err = encryptVectorPKE(s.rpk.epk, []byte(val))
-	return
+// ProcessRow processes a single row into an encrypted row, with the encrypted UID and the encrypted values, one
+// per column. In cardinality-only sessions, the rows have no values. In sessions with source proofs, use
+// [DataSource.ProcessRowWithProof] instead.
+func (s *DataSource) ProcessRow(uid string, vals ...string) (EncRow, error) {
+	if s.proofs {
+		return EncRow{}, fmt.Errorf("session with source proofs, use ProcessRowWithProof")
+	}
+	return s.processRow(uid, vals...)
+}
+
+// ProcessRowWithProof processes a single row into an encrypted row, which carries the proof of knowledge of
+// the randomness of its UID ciphertext in sessions with source proofs.
+func (s *DataSource) ProcessRowWithProof(uid string, vals ...string) (EncRow, error) {
+	if s.proofs && s.id == "" {
+		return EncRow{}, fmt.Errorf("source ID required in sessions with source proofs")
+	}
+	return s.processRow(uid, vals...)
+}
+
+func (s *DataSource) processRow(uid string, vals ...string) (EncRow, error) {
+	if s.cardinalityOnly && len(vals) > 0 {
+		return EncRow{}, fmt.Errorf("no values expected in cardinality-only sessions")
+	}
+	if !s.cardinalityOnly && len(vals) == 0 {
+		return EncRow{}, fmt.Errorf("at least one value required")
+	}
+	msg, err := s.oprfInput(uid)
+	if err != nil {
+		return EncRow{}, err
+	}
+	row := s.encryptUID(hashToMessage(msg, s.sid)) // blinds the OPRF input, as in oprfBlind
+	row.Cval = make([]EncValue, len(vals))
+	for i, val := range vals {
+		row.Cval[i], err = encryptValuePKE(s.valuePool, s.sid, []byte(val))
+		if err != nil {
+			return EncRow{}, err
+		}
+	}
+	return row, nil
+}
+
+// encryptUID encrypts the UID message towards the receiver, along with the proof of knowledge of the
+// encryption randomness in sessions with source proofs.
+func (s *DataSource) encryptUID(msg *message) EncRow {
+	cuid, r := s.uidPool.encrypt(msg)
+	if !s.proofs {
+		return EncRow{Cuid: cuid, session: sessionTag(s.sid)}
+	}
+	return EncRow{Cuid: cuid, Proof: proveSource(s.sid, s.id, s.rpk.bpk, &cuid, r), session: sessionTag(s.sid)}
+}
+
+// SessionID returns the ID of the data source's session, to be presented to the helper.
+func (s *DataSource) SessionID() SessionID {
+	return s.sid
+}
+
+// Precompute computes the encryption randomness of a table of rows rows with columns value columns, during
+// the idle time before the join. The randomness is kept in the pools of the data source, from which each
+// encryption of [DataSource.ProcessRow] and of the preparation methods takes one pair, and then costs a
+// single group operation. Once the pools are exhausted, the data source computes fresh randomness again.
+// The pairs are computed by the optional number of goroutines workers, until the cancellation of ctx, in
+// which case the pairs computed so far are kept.
+func (s *DataSource) Precompute(ctx context.Context, rows, columns int, goroutines ...int) error {
+	if rows < 0 || columns < 0 {
+		return fmt.Errorf("invalid number of rows %d or columns %d", rows, columns)
+	}
+
+	n := numWorkers(goroutines)
+
+	pools := make(chan *randomnessPool)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pool := range pools {
+				pool.push(newPKEPair(pool.pk))
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range rows * (1 + columns) {
+		pool := s.uidPool
+		if i%(1+columns) != 0 {
+			pool = s.valuePool
+		}
+		select {
+		case pools <- pool:
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+			break feed
+		}
+	}
+	close(pools)
+	wg.Wait()
+	return err
+}
+
+// PoolSize returns the number of precomputed UID and value encryptions left in the pools of the data source.
+func (s *DataSource) PoolSize() (uids, values int) {
+	return s.uidPool.size(), s.valuePool.size()
+}
+
+// SavePool moves the pools of the data source to a new file at path, to be loaded with [DataSource.LoadPool].
+// The pairs are removed from memory, and are discarded on error, so that they are never used twice. As the
+// file holds encryption randomness, it must be kept as secret as the plaintext table.
+func (s *DataSource) SavePool(path string) error {
+	data, err := marshalPools(s.rpk.bpk, s.rpk.epk, s.uidPool.take(), s.valuePool.take())
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadPool moves the pairs of a file written by [DataSource.SavePool] into the pools of the data source. The
+// file is removed before the pairs are used, and the pairs are discarded if it cannot be removed, so that a
+// file is loaded at most once. The pairs must have been computed for the receiver keys of the session.
+func (s *DataSource) LoadPool(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	uidPairs, valuePairs, err := unmarshalPools(data, s.rpk.bpk, s.rpk.epk)
+	if err != nil {
+		return err
+	}
+	s.uidPool.push(uidPairs...)
+	s.valuePool.push(valuePairs...)
+	return nil
+}
+
+// oprfInput returns the OPRF input for a UID, after normalization. For composite keys, it checks the UID
+// against the key schema, normalizes each part separately, and binds the schema to the input.
+func (s *DataSource) oprfInput(uid string) ([]byte, error) {
+	if len(s.keySchema) == 0 {
+		uid, err := normalize(s.normalization, uid)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(uid), nil
+	}
+	key, err := ParseCompositeKey(uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != len(s.keySchema) {
+		return nil, fmt.Errorf("composite key has %d parts, expected %d", len(key), len(s.keySchema))
+	}
+	for i := range key {
+		if key[i], err = normalize(s.normalization, key[i]); err != nil {
+			return nil, fmt.Errorf("key column %s: %w", s.keySchema[i], err)
+		}
+	}
+	return append(slices.Clip(s.keyPrefix), key.Encode()...), nil
+}
+
+// processDummyRow processes a dummy row, returning an encryption of a random UID point and encrypted dummy values.
+// As the UID point is not the hash of any UID, the row never matches in the join, and the dummy values are
+// discarded by the receiver in case the row is revealed, as in left joins.
+func (s *DataSource) processDummyRow(vals ...string) (EncRow, error) {
+	rmsg, err := randomMsg()
+	if err != nil {
+		return EncRow{}, err
+	}
+	row := s.encryptUID(rmsg)
+	row.Cval = make([]EncValue, len(vals))
+	for i, val := range vals {
+		row.Cval[i], err = encryptDummyValuePKE(s.valuePool, len(val))
+		if err != nil {
+			return EncRow{}, err
+		}
+	}
+	return row, nil
 }
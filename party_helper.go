@@ -1,10 +1,14 @@
 package mppj
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
-	"math/rand/v2"
-	"runtime"
+	"slices"
 	"sync"
 )
 
@@ -13,103 +17,549 @@ import (
 type Helper struct {
 	sid           []byte
 	sourceIndices map[PartyID]int
+	numColumns    []int
+	threshold     int
+	anchor        int
+	cardinality   bool
 	rpk           PublicKey
 
 	convK        *oprfKey
 	padKeyShares []*scalar
 	padKey       *scalar
+
+	proofs       bool
+	keyComs      KeyCommitments
+	sourceProofs bool
+	sourceKeys   map[PartyID]ed25519.PublicKey
+
+	// accepted holds the digests of the signed chunks accepted by [Helper.AcceptRows].
+	acceptedMu sync.Mutex
+	accepted   map[[32]byte]struct{}
+
+	// shuffleDir and shuffleBuckets configure the external shuffle of [Helper.ConvertStreamExternal].
+	shuffleDir     string
+	shuffleBuckets int
+}
+
+// HelperOption is an optional parameter of a Helper.
+type HelperOption func(*Helper)
+
+// WithExternalShuffle configures the external shuffle of [Helper.ConvertStreamExternal], which writes the
+// converted rows to the given number of buckets on disk. The temporary files are created in a new directory
+// inside dir, or inside the default directory for temporary files if dir is empty. The number of buckets should
+// be large enough for a bucket to fit in memory, and a number smaller than one selects a default of 256 buckets.
+func WithExternalShuffle(dir string, buckets int) HelperOption {
+	return func(h *Helper) {
+		h.shuffleDir, h.shuffleBuckets = dir, buckets
+		if buckets < 1 {
+			h.shuffleBuckets = defaultSpillBuckets
+		}
+	}
 }
 
 // NewHelper creates a new Helper for the given session.
-func NewHelper(sess *Session) *Helper {
-	c := &Helper{sid: sess.ID, sourceIndices: make(map[PartyID]int), rpk: sess.ReceiverPK}
+func NewHelper(sess *Session, opts ...HelperOption) *Helper {
+	c := &Helper{sid: sess.ID, sourceIndices: make(map[PartyID]int), numColumns: make([]int, len(sess.Sources)), threshold: sess.Threshold, cardinality: sess.CardinalityOnly, rpk: sess.ReceiverPK}
 	for i, source := range sess.Sources {
 		c.sourceIndices[source] = i
+		c.numColumns[i] = sess.NumColumns(source)
+	}
+	c.anchor = hiddenOrigin
+	if sess.Anchor != "" {
+		c.anchor = c.sourceIndices[sess.Anchor]
 	}
 	c.convK = oprfKeyGen()
-	c.padKeyShares, c.padKey = c.genNonces(len(sess.Sources))
+	if c.threshold > 0 {
+		c.padKeyShares, c.padKey = c.genShares(len(sess.Sources), c.threshold)
+	} else {
+		c.padKeyShares, c.padKey = c.genNonces(len(sess.Sources))
+	}
+	c.proofs = sess.HelperProofs
+	c.sourceProofs = sess.SourceProofs
+	c.sourceKeys = sess.SourceKeys
+	c.keyComs = c.commitments()
+	c.shuffleBuckets = defaultSpillBuckets
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
+// CheckSessionID returns an error if sid, as claimed by a source, differs from the helper's session ID. As the
+// session ID is bound to the session parameters, a mismatch typically results from a source configured with
+// different parameters, such as a different normalization chain. The conversion methods check the session ID
+// of every row, see [ConvertRowTask].
+func (h *Helper) CheckSessionID(sid SessionID) error {
+	if !bytes.Equal(sid, h.sid) {
+		return errSessionMismatch
+	}
+	return nil
+}
+
 // Convert converts the encrypted tables from data sources into a format suitable for joining by the receiver.
+// In sessions with registered source keys, use [Helper.ConvertSigned] instead.
 func (h *Helper) Convert(tables map[PartyID]EncTable) (EncTableWithHint, error) {
 
-	encRowsTasks := make(chan ConvertRowTask)
+	if len(h.sourceKeys) > 0 {
+		return nil, fmt.Errorf("session with signed uploads, use ConvertSigned")
+	}
+	return h.convert(tables)
+}
 
+func (h *Helper) convert(tables map[PartyID]EncTable) (EncTableWithHint, error) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return h.ConvertStream(ctx, h.rpk, h.feedTables(ctx, tables))
+}
+
+// feedTables streams the rows of tables as conversion tasks, until ctx is cancelled.
+func (h *Helper) feedTables(ctx context.Context, tables map[PartyID]EncTable) chan ConvertRowTask {
+	encRowsTasks := make(chan ConvertRowTask)
 	go func() {
+		defer close(encRowsTasks)
 		for sourceID, table := range tables {
 			for _, row := range table {
-				encRowsTasks <- ConvertRowTask{
-					EncRowMsg: EncRow{Cuid: row.Cuid, Cval: row.Cval},
-					SourceID:  sourceID,
+				select {
+				case encRowsTasks <- ConvertRowTask{EncRowMsg: row, SourceID: sourceID}:
+				case <-ctx.Done():
+					return
 				}
 			}
 		}
-		close(encRowsTasks)
 	}()
+	return encRowsTasks
+}
 
-	return h.ConvertStream(h.rpk, encRowsTasks)
+// feedTasks streams tasks, until ctx is cancelled.
+func feedTasks(ctx context.Context, tasks []ConvertRowTask) chan ConvertRowTask {
+	encRowsTasks := make(chan ConvertRowTask)
+	go func() {
+		defer close(encRowsTasks)
+		for _, task := range tasks {
+			select {
+			case encRowsTasks <- task:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return encRowsTasks
 }
 
 // ConvertRowTask represents a task to convert a single encrypted row from a data source.
 type ConvertRowTask struct {
 	EncRowMsg EncRow
 	SourceID  PartyID
+
+	// SessionID is the session ID claimed by the source, e.g., with [SessionIDFromIncomingContext] for
+	// rows received over the network, and may be nil. The helper rejects the rows claiming another session
+	// than its own, as well as the rows prepared in another session, whose serialization carries the tag of
+	// their session.
+	SessionID SessionID
+
+	// signer is the source whose signature over the row was verified by [Helper.AcceptRows], if any.
+	signer PartyID
 }
 
 // ConvertStream is the streaming version of [Convert]. It reads encrypted rows from the encRowsTasks channel,
 // processes them, and returns the converted table when all the rows have been processed. It is optionally possible to specify
-// the number of goroutines workers to use.
-func (h *Helper) ConvertStream(rpk PublicKey, encRowsTasks chan ConvertRowTask, goroutines ...int) (EncTableWithHint, error) {
+// the number of goroutines workers to use. In sessions with registered source keys, the tasks must be returned by
+// [Helper.AcceptRows], and the other rows are rejected with an error wrapping [ErrInvalidSignature].
+//
+// The workers stop on the cancellation of ctx or on the first error, after which ConvertStream stops reading from
+// encRowsTasks. The errors of individual rows are returned as a [*RowError], which identifies the row by its source
+// and its position among the rows of the source in encRowsTasks.
+func (h *Helper) ConvertStream(ctx context.Context, rpk PublicKey, encRowsTasks chan ConvertRowTask, goroutines ...int) (EncTableWithHint, error) {
+	res, _, err := h.convertStream(ctx, rpk, encRowsTasks, false, goroutines...)
+	return res, err
+}
+
+// ConvertStreamExternal is the external-memory variant of [Helper.ConvertStream], for inputs larger than the
+// helper's memory. The converted rows are written to random buckets on disk, see [WithExternalShuffle], which
+// are then shuffled one at a time and sent through the convRows channel, in a uniformly random order. The
+// workers stop on the cancellation of ctx or on the first error, which is then sent through the errc channel,
+// as a [*RowError] for the errors of individual rows. The errc channel is closed after convRows, and the
+// temporary files are removed before.
+func (h *Helper) ConvertStreamExternal(ctx context.Context, rpk PublicKey, encRowsTasks chan ConvertRowTask, goroutines ...int) (convRows <-chan EncRowWithHint, errc <-chan error) {
+	out, errs := make(chan EncRowWithHint), make(chan error, 1)
+	go func() {
+		err := h.convertExternal(ctx, rpk, encRowsTasks, out, numWorkers(goroutines))
+		close(out)
+		if err != nil {
+			errs <- err
+		}
+		close(errs)
+	}()
+	return out, errs
+}
+
+// convertExternal converts the rows of encRowsTasks with n workers, which write them to random buckets. As the
+// rows are assigned to the buckets independently and uniformly at random, shuffling each bucket and
+// concatenating the buckets results in a uniformly random permutation of the rows.
+func (h *Helper) convertExternal(ctx context.Context, rpk PublicKey, encRowsTasks chan ConvertRowTask, out chan<- EncRowWithHint, n int) error {
 
 	if h.padKey == nil || h.padKeyShares == nil {
-		return nil, errors.New("nonceerr, Nonces not generated. Please call GenNonces() before calling this function")
+		return errors.New("nonceerr, Nonces not generated. Please call GenNonces() before calling this function")
 	}
 
-	n := runtime.NumCPU()
-	if len(goroutines) > 0 && goroutines[0] > 0 {
-		n = goroutines[0]
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	buckets, err := newSpillBuckets(h.shuffleDir, h.shuffleBuckets)
+	if err != nil {
+		return fmt.Errorf("creating the buckets of the external shuffle: %w", err)
 	}
+	defer buckets.remove()
 
-	res := make(EncTableWithHint, 0)
-	mu := new(sync.Mutex)
+	tasks := indexTasks(ctx, encRowsTasks)
 
 	var wg sync.WaitGroup
 	for range n {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for encRow := range encRowsTasks {
-				convRow, err := h.ConvertRow(rpk, &encRow.EncRowMsg, encRow.SourceID)
+			rng := secureRand()
+			var sc convertScratch
+			for task := range tasks {
+				convRow, _, err := h.convertRow(rpk, &task.ConvertRowTask, nil, &sc)
 				if err != nil {
-					panic(err)
+					cancel(&RowError{Source: task.SourceID, Row: task.row, Err: err})
+					return
+				}
+				data, err := convRow.MarshalBinary()
+				if err == nil {
+					err = buckets.write(rng.IntN(buckets.len()), data)
+				}
+				if err != nil {
+					cancel(fmt.Errorf("writing to the buckets of the external shuffle: %w", err))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	if err := buckets.flush(); err != nil {
+		return fmt.Errorf("writing to the buckets of the external shuffle: %w", err)
+	}
+
+	rng := secureRand()
+	for i := range buckets.len() {
+		var rows EncTableWithHint
+		err := buckets.read(i, func(record []byte) error {
+			var row EncRowWithHint
+			if err := row.UnmarshalBinary(record); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := buckets.discard(i); err != nil {
+			return err
+		}
+		rng.Shuffle(len(rows), func(i, j int) {
+			rows[i], rows[j] = rows[j], rows[i]
+		})
+		for _, row := range rows {
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+	}
+	return nil
+}
+
+// ConvertWithShuffleProof is the variant of [Helper.Convert] that also returns a transcript proving that the
+// output rows are a permutation of the conversions of the input rows, for verification by an auditor with
+// [VerifyShuffle].
+func (h *Helper) ConvertWithShuffleProof(tables map[PartyID]EncTable) (EncTableWithHint, *ShuffleTranscript, error) {
+
+	if len(h.sourceKeys) > 0 {
+		return nil, nil, fmt.Errorf("session with signed uploads, use ConvertStreamWithShuffleProof on accepted rows")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return h.ConvertStreamWithShuffleProof(ctx, h.rpk, h.feedTables(ctx, tables))
+}
+
+// ConvertStreamWithShuffleProof is the variant of [Helper.ConvertStream] that also returns a transcript proving
+// that the output rows are a permutation of the conversions of the input rows, for verification by an auditor
+// with [VerifyShuffle].
+// As the proof is over the whole input, the conversion only starts after encRowsTasks is closed.
+func (h *Helper) ConvertStreamWithShuffleProof(ctx context.Context, rpk PublicKey, encRowsTasks chan ConvertRowTask, goroutines ...int) (EncTableWithHint, *ShuffleTranscript, error) {
+	return h.convertStream(ctx, rpk, encRowsTasks, true, goroutines...)
+}
+
+func (h *Helper) convertStream(ctx context.Context, rpk PublicKey, encRowsTasks chan ConvertRowTask, withProof bool, goroutines ...int) (EncTableWithHint, *ShuffleTranscript, error) {
+
+	if h.padKey == nil || h.padKeyShares == nil {
+		return nil, nil, errors.New("nonceerr, Nonces not generated. Please call GenNonces() before calling this function")
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	n := numWorkers(goroutines)
+
+	var tasks chan indexedTask
+	var transcript *ShuffleTranscript
+	var res EncTableWithHint
+
+	if !withProof {
+		tasks = indexTasks(ctx, encRowsTasks)
+	} else {
+		// the whole input is needed before shuffling
+		all := make([]indexedTask, 0)
+		counts := make(map[PartyID]int)
+	collect:
+		for {
+			select {
+			case task, more := <-encRowsTasks:
+				if !more {
+					break collect
+				}
+				all = append(all, indexedTask{ConvertRowTask: task, row: counts[task.SourceID]})
+				counts[task.SourceID]++
+			case <-ctx.Done():
+				return nil, nil, context.Cause(ctx)
+			}
+		}
+
+		shape := newShuffleShape(h.numColumns, h.cardinality, h.threshold)
+		origins, inputs := make([]int, len(all)), make([][]*Ciphertext, len(all))
+		rows := make([][]*Ciphertext, len(all))
+		for i := range all {
+			r := &all[i].EncRowMsg
+			tindex, err := h.checkRow(rpk, &all[i].ConvertRowTask)
+			if err != nil {
+				return nil, nil, &RowError{Source: all[i].SourceID, Row: all[i].row, Err: err}
+			}
+			origins[i], inputs[i] = tindex, append(make([]*Ciphertext, 0, 1+len(r.Cval)), &r.Cuid)
+			if !h.cardinality {
+				for k := range r.Cval {
+					inputs[i] = append(inputs[i], &r.Cval[k].CKey)
+				}
+			}
+			rows[i] = shape.row(inputs[i][0], inputs[i][1:], tindex)
+		}
+		shuffled, psi, proof := shuffleCiphertexts(h.sid, shape.keys(rpk), rows, func(j, k int) bool {
+			return shape.rerand(k, len(inputs[j])-1)
+		})
+		transcript = &ShuffleTranscript{origins: origins, inputs: inputs, shuffled: make([]*Ciphertext, len(all)), proof: proof, conversions: make([]conversionProof, len(all))}
+		for i, row := range shuffled {
+			transcript.shuffled[i] = row[0]
+		}
+		res = make(EncTableWithHint, len(all))
+
+		tasks = make(chan indexedTask)
+		go func() {
+			defer close(tasks)
+			for i, j := range psi {
+				all[j].pos, all[j].shuffled = i, shuffled[i]
+				select {
+				case tasks <- all[j]:
+				case <-ctx.Done():
+					return
 				}
-				mu.Lock()
-				res = append(res, *convRow)
-				mu.Unlock()
+			}
+		}()
+	}
+
+	outs := make([]EncTableWithHint, n) // the output rows of each worker, without a shuffle proof
+
+	var wg sync.WaitGroup
+	for w := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var sc convertScratch
+			for task := range tasks {
+				convRow, conv, err := h.convertRow(rpk, &task.ConvertRowTask, task.shuffled, &sc)
+				if err != nil {
+					cancel(&RowError{Source: task.SourceID, Row: task.row, Err: err})
+					return
+				}
+				if withProof {
+					res[task.pos], transcript.conversions[task.pos] = convRow, *conv
+					continue
+				}
+				outs[w] = append(outs[w], convRow)
 			}
 		}()
 	}
 
 	wg.Wait()
 
-	rand.Shuffle(len(res), func(i, j int) { // TODO: use proper RNG
+	if err := context.Cause(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	if withProof {
+		return res, transcript, nil // already shuffled
+	}
+
+	res = slices.Concat(outs...)
+	secureRand().Shuffle(len(res), func(i, j int) {
 		res[i], res[j] = res[j], res[i]
 	})
 
-	return res, nil
+	return res, nil, nil
 }
 
-// ConvertRow converts a single row `r` received from datasource sourceID.
-func (h *Helper) ConvertRow(rpk PublicKey, r *EncRow, sourceID PartyID) (*EncRowWithHint, error) {
+// indexedTask is a conversion task, along with the position of its row among the rows of its source.
+type indexedTask struct {
+	ConvertRowTask
+	row      int
+	pos      int           // the position of the row in the output, in shuffle proof mode
+	shuffled []*Ciphertext // the shuffled row of ciphertexts, in shuffle proof mode
+}
 
-	joinid := *oprfEval(h.convK, rpk.bpk, r.Cuid) // ReRand internally
+// indexTasks forwards the tasks of encRowsTasks with the positions of their rows, until ctx is cancelled.
+func indexTasks(ctx context.Context, encRowsTasks chan ConvertRowTask) chan indexedTask {
+	tasks := make(chan indexedTask)
+	go func() {
+		defer close(tasks)
+		counts := make(map[PartyID]int)
+		for {
+			select {
+			case task, more := <-encRowsTasks:
+				if !more {
+					return
+				}
+				select {
+				case tasks <- indexedTask{ConvertRowTask: task, row: counts[task.SourceID]}:
+					counts[task.SourceID]++
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return tasks
+}
 
-	ad, blindedkey, hint, err := h.blindAndHint(rpk, &joinid, r.Cval, h.sourceIndices[sourceID])
+// ConvertRow converts a single row `r` received from datasource sourceID. In sessions with source proofs, it
+// returns an error wrapping [ErrInvalidProof] if the row's proof is missing or invalid for sourceID. In sessions
+// with registered source keys, the rows are converted from the tasks of [Helper.AcceptRows] instead, and
+// ConvertRow returns an error wrapping [ErrInvalidSignature].
+func (h *Helper) ConvertRow(rpk PublicKey, r *EncRow, sourceID PartyID) (*EncRowWithHint, error) {
+	task := ConvertRowTask{EncRowMsg: *r, SourceID: sourceID}
+	convRow, _, err := h.convertRow(rpk, &task, nil, new(convertScratch))
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	return &convRow, nil
+}
+
+// errMissingUID is returned for the rows without a UID ciphertext.
+var errMissingUID = errors.New("missing UID ciphertext")
+
+// errMissingSession is returned for the rows without a session tag, which were not prepared by a data source.
+var errMissingSession = errors.New("missing session tag")
+
+// errSessionMismatch is returned for the rows of the sources presenting another session than the helper's.
+var errSessionMismatch = errors.New("session ID mismatch: the source and helper session parameters differ")
+
+// convertScratch holds the buffers that a conversion worker reuses across rows, so that the converted rows only
+// allocate their own ciphertexts and payload.
+type convertScratch struct {
+	t      point      // for the re-randomizations
+	rp     point      // the ephemeral point of the payload key
+	values []EncValue // the re-randomized values of the row
+	buf    []byte     // the plaintext of the payload
+	ad     []byte     // the associated data of the payload
+	kdf    kdf        // for the payload key
+}
+
+// checkRow checks the row of the task before its conversion, and returns the index of its source.
+func (h *Helper) checkRow(rpk PublicKey, task *ConvertRowTask) (int, error) {
+
+	r, sourceID := &task.EncRowMsg, task.SourceID
+	tindex, ok := h.sourceIndices[sourceID]
+	if !ok {
+		return 0, fmt.Errorf("unknown source %s", sourceID)
+	}
+	if len(h.sourceKeys) > 0 && task.signer != sourceID {
+		return 0, fmt.Errorf("%w: row of source %s not accepted", ErrInvalidSignature, sourceID)
+	}
+	if task.SessionID != nil {
+		if err := h.CheckSessionID(task.SessionID); err != nil {
+			return 0, err
+		}
+	}
+	if len(r.session) == 0 {
+		return 0, errMissingSession
+	}
+	if !bytes.Equal(r.session, sessionTag(h.sid)) {
+		return 0, errSessionMismatch
+	}
+	if !r.hasUID() {
+		return 0, errMissingUID
+	}
+	if h.sourceProofs && !verifySource(h.sid, sourceID, rpk.bpk, &r.Cuid, r.Proof) {
+		return 0, fmt.Errorf("%w: row of source %s", ErrInvalidProof, sourceID)
 	}
-	return &EncRowWithHint{Cnyme: joinid, CVal: ad, CValKey: *blindedkey, CHint: *hint}, nil
+	if len(r.Cval) != h.numColumns[tindex] {
+		return 0, fmt.Errorf("source %s sent %d values, expected %d", sourceID, len(r.Cval), h.numColumns[tindex])
+	}
+	return tindex, nil
+}
+
+// convertRow converts the row of the task. In shuffle proof mode, shuffled is the row of ciphertexts output by
+// the shuffle at the position of the converted row, which replace the UID ciphertext and the value ciphertexts
+// of the task, and convertRow also returns the proof of the conversion. The rows are then checked before the
+// shuffle. Otherwise, shuffled is nil.
+func (h *Helper) convertRow(rpk PublicKey, task *ConvertRowTask, shuffled []*Ciphertext, sc *convertScratch) (EncRowWithHint, *conversionProof, error) {
+
+	r := &task.EncRowMsg
+	tindex := h.sourceIndices[task.SourceID]
+	if shuffled == nil {
+		var err error
+		if tindex, err = h.checkRow(rpk, task); err != nil {
+			return EncRowWithHint{}, nil, err
+		}
+	}
+
+	var convRow EncRowWithHint
+	var proof *conversionProof
+	var values []*Ciphertext // the shuffled value ciphertexts
+	if shuffled != nil {
+		values = shuffled[1 : 1+len(r.Cval)]
+		proof = &conversionProof{eval: convRow.Cnyme.setOPRFEvalWithProof(h.convK, []*point{h.keyComs.conv}, 0, rpk.bpk, shuffled[0], h.sid, &sc.t)}
+	} else {
+		convRow.Cnyme.setOPRFEval(h.convK, rpk.bpk, &r.Cuid, randomScalar(), &sc.t) // ReRand internally
+	}
+
+	if h.cardinality {
+		convRow.Origin = hiddenOrigin
+		if h.threshold > 0 {
+			convRow.Origin = tindex
+		}
+		return convRow, proof, nil
+	}
+
+	convRow.Origin = hiddenOrigin
+	if h.threshold > 0 || tindex == h.anchor {
+		convRow.Origin = tindex // required by the receiver for reconstructing the mask from the hints
+	}
+	if err := h.blindAndHint(rpk, &convRow, r.Cval, values, tindex, sc, proof); err != nil {
+		return EncRowWithHint{}, nil, err
+	}
+	return convRow, proof, nil
 }
 
 func (h *Helper) genNonces(nSources int) ([]*scalar, *scalar) {
@@ -124,24 +574,127 @@ func (h *Helper) genNonces(nSources int) ([]*scalar, *scalar) {
 	return nonces, nonceSum
 }
 
-func (h *Helper) blindAndHint(rpk PublicKey, joinid *Ciphertext, value []*Ciphertext, tindex int) ([]byte, *Ciphertext, *Ciphertext, error) {
+// genShares generates a Shamir secret-sharing of a random pad key, with reconstruction threshold k.
+// The share of the source at index i is the evaluation of the sharing polynomial at i+1.
+func (h *Helper) genShares(nSources, k int) ([]*scalar, *scalar) {
+
+	coeffs := make([]*scalar, k)
+	for i := range coeffs {
+		coeffs[i] = randomScalar()
+	}
+
+	shares := make([]*scalar, nSources)
+	for i := range nSources {
+		x := newScalar(big.NewInt(int64(i + 1)))
+		share := coeffs[k-1].Copy()
+		for j := k - 2; j >= 0; j-- {
+			share = share.mul(x).add(coeffs[j])
+		}
+		shares[i] = share
+	}
+
+	return shares, coeffs[0]
+}
+
+// evalHint sets hint to joinid^key, and returns its proof in sessions with helper proofs, with respect to the
+// commitments keyComs, among which keyComs[index] is the commitment to key. The point t is a scratch point of
+// the caller.
+func (h *Helper) evalHint(hint *Ciphertext, key *scalar, keyComs []*point, index int, bpk *publicKey, joinid *Ciphertext, t *point) *HintProof {
+	if !h.proofs {
+		hint.setOPRFEval((*oprfKey)(key), bpk, joinid, randomScalar(), t) // ReRand internally
+		return nil
+	}
+	return hint.setOPRFEvalWithProof((*oprfKey)(key), keyComs, index, bpk, joinid, h.sid, t)
+}
+
+// appendPayloadAD appends to dst the associated data of the symmetric encryption of a row's values: the session
+// ID and the row's origin as revealed to the receiver. For rows with hidden origins, the source index is part of
+// the authenticated plaintext.
+func appendPayloadAD(dst, sid []byte, origin int) []byte {
+	dst = append(dst, sid...)
+	return binary.BigEndian.AppendUint16(dst, uint16(origin)) // hidden origins are encoded as 0xFFFF
+}
 
-	rp, key := randomKeyFromPoint(h.sid)
+// blindAndHint sets the payload, the blinded payload key and the hint of the converted row, from its pseudonym
+// and origin and from the values of the source row. In shuffle proof mode, the value ciphertexts of the payload
+// are the shuffled ones, and blindAndHint sets the proof of the payload in proof.
+func (h *Helper) blindAndHint(rpk PublicKey, row *EncRowWithHint, values []EncValue, shuffled []*Ciphertext, tindex int, sc *convertScratch, proof *conversionProof) error {
 
-	serialized, err := serializeCiphertexts(reRandVector(rpk.epk, value))
+	p := randomScalar()
+	rp := sc.rp.setBaseExp(p) // a random point, as in randomKeyFromPoint
+	key, err := sc.kdf.key(rp, h.sid, helperKeyInfo)
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
 
-	ad, err := symmetricEncrypt(key, append([]byte{byte(tindex)}, serialized...)) // append the table pos for in order reconstruction
+	sc.values = slices.Grow(sc.values[:0], len(values))[:len(values)]
+	for i := range values {
+		if shuffled != nil {
+			sc.values[i].CKey.c0.set(&shuffled[i].c0) // already re-randomized by the shuffle
+			sc.values[i].CKey.c1.set(&shuffled[i].c1)
+		} else {
+			sc.values[i].CKey.setReRand(rpk.epk, &values[i].CKey, randomScalar(), &sc.t)
+		}
+		sc.values[i].Data = values[i].Data
+	}
+
+	sc.buf = binary.BigEndian.AppendUint16(sc.buf[:0], uint16(tindex)) // append the table pos for in order reconstruction
+	if sc.buf, err = appendEncValues(sc.buf, sc.values); err != nil {
+		return err
+	}
+	sc.ad = appendPayloadAD(sc.ad[:0], h.sid, row.Origin)
+	row.CVal, err = symmetricEncrypt(key, sc.buf, sc.ad)
+	sc.kdf.clear()
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
 
-	blindkey := oprfEval((*oprfKey)(h.padKey), rpk.bpk, joinid) // ReRand internally
-	blindkey.c1 = mul(blindkey.c1, rp)                          // blind the ephemeral point using joinid ^ s
+	r := randomScalar()
+	if tindex == h.anchor {
+		// the anchor rows are not blinded, and their hint reveals joinid ^ s for unblinding the other rows of the group
+		row.CValKey.setEncrypt(rpk.bpk, &message{m: *rp}, r)
+		if h.proofs {
+			row.KeyProof = proveValueKey(newScalar(big.NewInt(0)), identity(), rpk.bpk, &row.Cnyme, &row.CValKey, r, p, h.sid, &sc.t)
+		}
+		if proof != nil {
+			h.provePayload(proof, row, newScalar(big.NewInt(0)), identity(), r, rp, rpk.bpk, &sc.t)
+		}
+		row.Proof = h.evalHint(&row.CHint, h.padKey, []*point{h.keyComs.pad}, 0, rpk.bpk, &row.Cnyme, &sc.t)
+		return nil
+	}
+
+	row.CValKey.setOPRFEval((*oprfKey)(h.padKey), rpk.bpk, &row.Cnyme, r, &sc.t) // ReRand internally
+	row.CValKey.c1.setMul(&row.CValKey.c1, rp)                                   // blind the ephemeral point using joinid ^ s
+	if h.proofs {
+		row.KeyProof = proveValueKey(h.padKey, h.keyComs.pad, rpk.bpk, &row.Cnyme, &row.CValKey, r, p, h.sid, &sc.t)
+	}
+	if proof != nil {
+		h.provePayload(proof, row, h.padKey, h.keyComs.pad, r, rp, rpk.bpk, &sc.t)
+	}
 
-	hint := oprfEval((*oprfKey)(h.padKeyShares[tindex]), rpk.bpk, joinid) // ReRand internally
+	if h.anchor != hiddenOrigin {
+		rmsg, err := randomMsg() // the hints of non-anchor rows are not used in left joins
+		if err != nil {
+			return err
+		}
+		row.CHint.setEncrypt(rpk.bpk, rmsg, randomScalar())
+		return nil
+	}
+
+	if h.threshold > 0 {
+		row.Proof = h.evalHint(&row.CHint, h.padKeyShares[tindex], h.keyComs.shares[tindex:tindex+1], 0, rpk.bpk, &row.Cnyme, &sc.t)
+		return nil
+	}
+	// the origins of the rows are hidden, so the proof does not tell the share among all the shares
+	row.Proof = h.evalHint(&row.CHint, h.padKeyShares[tindex], h.keyComs.shares, tindex, rpk.bpk, &row.Cnyme, &sc.t)
+	return nil
+}
 
-	return ad, blindkey, hint, nil
+// provePayload sets the point rp of the payload key of row in its conversion proof, along with the proof that
+// the value key divided by rp is the pseudonym raised to key, with randomness r, for the commitment keyCom. The
+// point t is a scratch point of the caller.
+func (h *Helper) provePayload(proof *conversionProof, row *EncRowWithHint, key *scalar, keyCom *point, r *scalar, rp *point, bpk *publicKey, t *point) {
+	proof.rp = new(point).set(rp)
+	unblinded := &Ciphertext{c0: row.CValKey.c0, c1: *mul(&row.CValKey.c1, rp.invert())}
+	proof.payload = proveOPRFEval((*oprfKey)(key), r, []*point{keyCom}, 0, bpk, &row.Cnyme, unblinded, h.sid, t)
 }
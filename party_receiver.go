@@ -1,33 +1,81 @@
 package mppj
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
-	"runtime"
+	"math/big"
+	"slices"
 	"sync"
 )
 
 // Receiver represents the receiver party in the MPPJ protocol, for a given session. Its main method is JoinTables, which
 // joins the converted encrypted tables from the helper.
 type Receiver struct {
-	sid       []byte
-	sourceIDs []PartyID
-	recvSK    SecretKey
-	recvPK    PublicKey
+	sid         []byte
+	sourceIDs   []PartyID
+	columns     map[PartyID][]string
+	threshold   int
+	anchor      int
+	cardinality bool
+	proofs      bool
+	keyComs     *KeyCommitments
+	recvSK      SecretKey
+	recvPK      PublicKey
+
+	// spillDir and spillBuckets configure the out-of-core mode, which is enabled if spillBuckets is positive.
+	spillDir     string
+	spillBuckets int
+}
+
+// ReceiverOption is an optional parameter of a Receiver.
+type ReceiverOption func(*Receiver)
+
+// WithOutOfCore makes the receiver join tables larger than its memory: the received rows are partitioned
+// by pseudonym into the given number of buckets on disk, which are then grouped and decrypted one at a time.
+// The temporary files are created in a new directory inside dir, or inside the default directory for
+// temporary files if dir is empty, and are removed when the join completes or fails. A number of buckets
+// smaller than one selects a default of 256 buckets.
+func WithOutOfCore(dir string, buckets int) ReceiverOption {
+	return func(r *Receiver) {
+		r.spillDir, r.spillBuckets = dir, buckets
+		if buckets < 1 {
+			r.spillBuckets = defaultSpillBuckets
+		}
+	}
 }
 
 // NewReceiver creates a new receiver for the given session.
-func NewReceiver(sess *Session, sk SecretKey) *Receiver {
+func NewReceiver(sess *Session, sk SecretKey, opts ...ReceiverOption) *Receiver {
 	r := &Receiver{
-		sid:       sess.ID,
-		sourceIDs: make([]PartyID, len(sess.Sources)),
-		recvSK:    sk,
-		recvPK:    sess.ReceiverPK,
+		sid:         sess.ID,
+		sourceIDs:   make([]PartyID, len(sess.Sources)),
+		columns:     sess.Columns,
+		threshold:   sess.Threshold,
+		cardinality: sess.CardinalityOnly,
+		proofs:      sess.HelperProofs,
+		recvSK:      sk,
+		recvPK:      sess.ReceiverPK,
 	}
 	copy(r.sourceIDs, sess.Sources)
+	r.anchor = slices.Index(r.sourceIDs, sess.Anchor) // -1, i.e., hiddenOrigin, if no anchor
+	for _, opt := range opts {
+		opt(r)
+	}
 	return r
 }
 
+// SetHelperCommitments sets the helper's key commitments, against which the receiver verifies the helper's
+// proofs in sessions with helper proofs. It returns an error if the commitments are inconsistent.
+func (r *Receiver) SetHelperCommitments(com KeyCommitments) error {
+	if err := com.check(len(r.sourceIDs), r.threshold); err != nil {
+		return err
+	}
+	r.keyComs = &com
+	return nil
+}
+
 // JoinTables extracts the intersection from the joined tables received from the helper.
 func (r *Receiver) JoinTables(joinedTables EncTableWithHint) (JoinTable, error) {
 
@@ -40,43 +88,242 @@ func (r *Receiver) JoinTables(joinedTables EncTableWithHint) (JoinTable, error)
 		}
 	}()
 
-	return r.JoinTablesStream(encrows)
+	return r.JoinTablesStream(context.Background(), encrows)
 
 }
 
 // JoinTablesStream is the streaming version of [JoinTables]. It reads encrypted rows from the in channel,
 // processes them, and returns the joined table when all the rows have been processed. It is optionally possible to specify
 // the number of goroutines workers to use.
-func (r *Receiver) JoinTablesStream(in chan EncRowWithHint, goroutines ...int) (JoinTable, error) {
+//
+// The workers stop on the cancellation of ctx or on the first error, after which JoinTablesStream stops reading from in.
+// The errors of individual rows are returned as a [*RowError], which identifies the row by its position in in, and by
+// its source when the source is known to the receiver.
+func (r *Receiver) JoinTablesStream(ctx context.Context, in chan EncRowWithHint, goroutines ...int) (JoinTable, error) {
+	if r.cardinality {
+		return JoinTable{}, fmt.Errorf("cardinality-only session, use CountTables")
+	}
+	if r.proofs && r.keyComs == nil {
+		return JoinTable{}, fmt.Errorf("helper commitments required to verify the helper proofs")
+	}
+	n := numWorkers(goroutines)
+	groups, cleanup, err := r.groups(ctx, in, n)
+	if err != nil {
+		return JoinTable{}, err
+	}
+	defer cleanup()
+	return r.intersectHint(ctx, groups, n)
+}
+
+// CountTables returns the size of the join of the tables received from the helper, in cardinality-only
+// sessions. It is the number of UIDs present in all sources, or in at least k sources in threshold sessions.
+func (r *Receiver) CountTables(joinedTables EncTableWithHint) (int, error) {
+
+	encrows := make(chan EncRowWithHint, len(joinedTables))
 
-	n := runtime.NumCPU()
-	if len(goroutines) > 0 && goroutines[0] > 0 {
-		n = goroutines[0]
+	go func() {
+		defer close(encrows)
+		for _, ct := range joinedTables {
+			encrows <- ct
+		}
+	}()
+
+	return r.CountTablesStream(context.Background(), encrows)
+}
+
+// CountTablesStream is the streaming version of [CountTables]. It handles cancellation and errors as
+// [Receiver.JoinTablesStream].
+func (r *Receiver) CountTablesStream(ctx context.Context, in chan EncRowWithHint, goroutines ...int) (int, error) {
+	if !r.cardinality {
+		return 0, fmt.Errorf("not a cardinality-only session, use JoinTables")
+	}
+	groups, cleanup, err := r.groups(ctx, in, numWorkers(goroutines))
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+	count := 0
+	err = groups(func(group []EncRowWithHint) bool {
+		if r.isComplete(group) {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// groupFeed calls yield on each group of rows with the same pseudonym, until yield returns false.
+type groupFeed func(yield func(group []EncRowWithHint) bool) error
+
+// groups reads the encrypted rows from the in channel with n workers, and returns the feed of their groups,
+// which are held in memory or, in out-of-core mode, in buckets on disk. The returned cleanup function removes
+// the temporary files of the feed.
+func (r *Receiver) groups(ctx context.Context, in chan EncRowWithHint, n int) (groupFeed, func(), error) {
+	if r.spillBuckets < 1 {
+		groups, err := r.group(ctx, in, n)
+		if err != nil {
+			return nil, nil, err
+		}
+		return groups.feed, func() {}, nil
 	}
 
-	groups := make(map[string][]EncRowWithHint)
+	buckets, err := newSpillBuckets(r.spillDir, r.spillBuckets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating the buckets of the out-of-core receiver: %w", err)
+	}
+	err = r.pseudonymize(ctx, in, n, func(_ int, nym []byte, row EncRowWithHint) error {
+		if err := buckets.writeGrouped(nym, row); err != nil {
+			return fmt.Errorf("writing to the buckets of the out-of-core receiver: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		buckets.remove()
+		return nil, nil, err
+	}
+	return buckets.feedGroups, func() { buckets.remove() }, nil
+}
+
+// groupShards is the number of shards of the groups of rows, which are keyed by a byte of the pseudonyms.
+const groupShards = 256
+
+// pseudonymGroups are the rows grouped by pseudonym, in shards that are built independently of each other.
+type pseudonymGroups [groupShards]map[string][]EncRowWithHint
+
+// groupShard returns the shard of the group of the pseudonym p. It is the second byte of p, as the first byte
+// of the compressed encodings of all the suites includes a sign bit. The encoding of the identity may be shorter.
+func groupShard(p []byte) int {
+	if len(p) < 2 {
+		return 0
+	}
+	return int(p[1]) % groupShards
+}
+
+// feed calls yield on the groups, shard by shard.
+func (g *pseudonymGroups) feed(yield func(group []EncRowWithHint) bool) error {
+	for _, shard := range g {
+		for _, group := range shard {
+			if !yield(group) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// pseudonymize reads the encrypted rows from the in channel, and calls emit with the pseudonym of each row
+// from n workers, identified by w. The workers stop on the cancellation of ctx or on the first error.
+func (r *Receiver) pseudonymize(ctx context.Context, in chan EncRowWithHint, n int, emit func(w int, nym []byte, row EncRowWithHint) error) error {
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	rows := make(chan EncRowWithHint)
+	go func() {
+		defer close(rows)
+		for pos := 0; ; pos++ {
+			select {
+			case row, more := <-in:
+				if !more {
+					return
+				}
+				row.pos = pos
+				select {
+				case rows <- row:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	for range n {
+	for w := range n {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for ciphertexts := range in {
-				msgPRF, err := oprfUnblind(r.recvSK.bsk, &ciphertexts.Cnyme).GetMessageBytes()
+			var nym message
+			for ciphertexts := range rows {
+				msgPRF, err := nym.setDecrypt(r.recvSK.bsk, &ciphertexts.Cnyme).m.MarshalBinary() // pseudonyms are not embeddings, as in oprfUnblind
 				if err != nil {
-					log.Fatalf("Decryption error: %v", err)
+					cancel(r.rowError(ciphertexts, ciphertexts.Origin, fmt.Errorf("decryption error: %w", err)))
+					return
 				}
 
-				mu.Lock()
-				groups[string(msgPRF)] = append(groups[string(msgPRF)], ciphertexts)
-				mu.Unlock()
+				if err := emit(w, msgPRF, ciphertexts); err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// group reads the encrypted rows from the in channel, and groups them by pseudonym with n workers. The
+// workers buffer their rows by shard, and the shards are then merged from the buffers by one worker each,
+// so that the workers never contend on a lock.
+func (r *Receiver) group(ctx context.Context, in chan EncRowWithHint, n int) (*pseudonymGroups, error) {
+
+	type nymRow struct {
+		nym string
+		row EncRowWithHint
+	}
+	buffers := make([][groupShards][]nymRow, n)
+
+	err := r.pseudonymize(ctx, in, n, func(w int, nym []byte, row EncRowWithHint) error {
+		shard := groupShard(nym)
+		buffers[w][shard] = append(buffers[w][shard], nymRow{nym: string(nym), row: row})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wg := sync.WaitGroup{}
+
+	groups := new(pseudonymGroups)
+	shards := make(chan int)
+	go func() {
+		defer close(shards)
+		for shard := range groupShards {
+			shards <- shard
+		}
+	}()
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				groups[shard] = make(map[string][]EncRowWithHint)
+				for w := range buffers {
+					for _, nr := range buffers[w][shard] {
+						groups[shard][nr.nym] = append(groups[shard][nr.nym], nr.row)
+					}
+					buffers[w][shard] = nil
+				}
 			}
 		}()
 	}
 	wg.Wait()
 
-	return r.intersectHint(groups)
+	return groups, nil
+}
+
+// rowError returns the error of a row, given the index of its source, or hiddenOrigin if it is unknown.
+func (r *Receiver) rowError(ge EncRowWithHint, sourceIndex int, err error) *RowError {
+	rerr := &RowError{Row: ge.pos, Err: err}
+	if sourceIndex >= 0 && sourceIndex < len(r.sourceIDs) {
+		rerr.Source = r.sourceIDs[sourceIndex]
+	}
+	return rerr
 }
 
 // GetPK returns the receiver's public key.
@@ -89,102 +336,315 @@ func (r *Receiver) GetSK() SecretKey {
 	return r.recvSK
 }
 
-type encValueWithHint struct {
-	val        SymmetricCiphertext
-	blindedkey message
-	hint       message
-}
+// errIncompleteGroup is returned when a group does not contain rows from enough distinct sources.
+var errIncompleteGroup = errors.New("incomplete group")
 
-func (r *Receiver) decryptGroup(group []EncRowWithHint) (map[PartyID]string, error) {
-	decGroup := make([]encValueWithHint, len(group))
+// groupMask reconstructs the mask blinding the value keys of a group from the group's hints, into the mask of
+// the scratch. As the hints of the rows of the same source are equal, each distinct hint is accounted for once.
+// In threshold sessions, the mask is interpolated in the exponent from the hints of the first k distinct
+// sources. In left join sessions, the mask is the hint of an anchor row.
+func (r *Receiver) groupMask(group []EncRowWithHint, sc *decryptScratch) (*point, error) {
+	mask := &sc.mask.m
 
-	for i, ge := range group {
-		decGroup[i] = encValueWithHint{
-			val:        ge.CVal,
-			blindedkey: *oprfUnblind(r.recvSK.bsk, &ge.CValKey),
-			hint:       *oprfUnblind(r.recvSK.bsk, &ge.CHint),
+	if r.anchor != hiddenOrigin {
+		i := slices.IndexFunc(group, func(ge EncRowWithHint) bool { return ge.Origin == r.anchor })
+		if i == -1 {
+			return nil, fmt.Errorf("group has no anchor row")
 		}
+		return &sc.mask.setDecrypt(r.recvSK.bsk, &group[i].CHint).m, nil // as in oprfUnblind
 	}
 
-	mask := identity()
-	for _, dge := range decGroup {
-		mask = mul(mask, &dge.hint.m)
+	if r.threshold == 0 {
+		hints := sc.hints[:0]
+		for _, ge := range group {
+			hints = slices.Grow(hints, 1)[:len(hints)+1]
+			hint := &hints[len(hints)-1]
+			hint.setDecrypt(r.recvSK.bsk, &ge.CHint) // as in oprfUnblind
+			if slices.ContainsFunc(hints[:len(hints)-1], func(h message) bool { return h.m.Equals(&hint.m) }) {
+				hints = hints[:len(hints)-1]
+				continue
+			}
+			if len(hints) == 1 {
+				mask.set(&hint.m)
+			} else {
+				mask.setMul(mask, &hint.m)
+			}
+		}
+		sc.hints = hints
+		if len(hints) != len(r.sourceIDs) {
+			return nil, errIncompleteGroup
+		}
+		return mask, nil
 	}
-	invMask := mask.invert()
 
-	out := make(map[PartyID]string, len(group))
-	for _, dge := range decGroup {
-		keyp := mul(&dge.blindedkey.m, invMask)
-		key, err := keyFromPoint(keyp, r.sid)
-		if err != nil {
-			panic(err)
+	rows := make([]EncRowWithHint, 0, r.threshold)
+	xs := make([]*scalar, 0, r.threshold)
+	for _, ge := range group {
+		if len(rows) == r.threshold {
+			break
 		}
+		if slices.ContainsFunc(rows, func(row EncRowWithHint) bool { return row.Origin == ge.Origin }) {
+			continue
+		}
+		rows = append(rows, ge)
+		xs = append(xs, newScalar(big.NewInt(int64(ge.Origin+1))))
+	}
+	if len(rows) < r.threshold {
+		return nil, errIncompleteGroup
+	}
 
-		encAttridValBytes, err := symmetricDecrypt(key, dge.val)
-		if err != nil {
-			panic(err)
+	hint := &sc.key.m
+	for j, row := range rows {
+		sc.key.setDecrypt(r.recvSK.bsk, &row.CHint) // as in oprfUnblind
+		hint.setScalarExp(hint, lagrangeCoefficient(xs, j))
+		if j == 0 {
+			mask.set(hint)
+		} else {
+			mask.setMul(mask, hint)
 		}
+	}
+	return mask, nil
+}
 
-		if len(encAttridValBytes) == 0 {
-			panic(fmt.Errorf("incorrect encrypted attribute value"))
+// lagrangeCoefficient returns the Lagrange coefficient at zero of the j-th evaluation point in xs.
+func lagrangeCoefficient(xs []*scalar, j int) *scalar {
+	return lagrangeCoefficientAt(xs, j, newScalar(big.NewInt(0)))
+}
+
+// lagrangeCoefficientAt returns the Lagrange coefficient at x of the j-th evaluation point in xs.
+func lagrangeCoefficientAt(xs []*scalar, j int, x *scalar) *scalar {
+	num := newScalar(big.NewInt(1))
+	den := newScalar(big.NewInt(1))
+	for m, xm := range xs {
+		if m == j {
+			continue
 		}
+		num = num.mul(x.sub(xm))
+		den = den.mul(xs[j].sub(xm))
+	}
+	return num.mul(den.inv())
+}
 
-		sourceIndex, encValBytes := int(encAttridValBytes[0]), encAttridValBytes[1:]
-		if sourceIndex < 0 || sourceIndex >= len(r.sourceIDs) {
-			panic(fmt.Errorf("invalid source index: %d", sourceIndex))
+// isComplete returns whether a group of rows with the same pseudonym is part of the join.
+// For regular joins, it only checks the size of the group, and the distinct sources are counted in groupMask.
+func (r *Receiver) isComplete(group []EncRowWithHint) bool {
+	if r.anchor != hiddenOrigin {
+		return slices.ContainsFunc(group, func(ge EncRowWithHint) bool { return ge.Origin == r.anchor })
+	}
+	if r.threshold == 0 {
+		return len(group) >= len(r.sourceIDs)
+	}
+	origins := make(map[int]struct{}, len(group))
+	for _, ge := range group {
+		if ge.Origin < 0 || ge.Origin >= len(r.sourceIDs) {
+			return false
 		}
-		sourceID := r.sourceIDs[sourceIndex]
+		origins[ge.Origin] = struct{}{}
+	}
+	return len(origins) >= r.threshold
+}
 
-		encVal, err := deserializeCiphertexts(encValBytes)
-		if err != nil {
-			panic(err)
+// decryptScratch holds the buffers that a decryption worker reuses across groups, so that the decryption of a
+// group only allocates its output values.
+type decryptScratch struct {
+	mask   message    // the mask of the group
+	key    message    // the payload key point of a row
+	hints  []message  // the distinct hints of the group
+	msg    message    // the public-key part of a value
+	values []EncValue // the encrypted values of a row
+	buf    []byte     // the payload of a row
+	ad     []byte     // the associated data of the payload
+	kdf    kdf        // for the symmetric keys of the row and of its values
+}
+
+// decryptGroup decrypts the values of a complete group, as a list of rows per source, with the scratch of the
+// calling worker. It returns a nil map if the group is not part of the join.
+func (r *Receiver) decryptGroup(group []EncRowWithHint, sc *decryptScratch) (map[PartyID][][]string, error) {
+
+	if r.proofs {
+		if err := r.verifyGroup(group); err != nil {
+			return nil, err
 		}
+	}
 
-		plantext_data, err := decryptVectorPKE(r.recvSK.esk, encVal)
-		if err != nil {
-			panic(err)
+	mask, err := r.groupMask(group, sc)
+	if err == errIncompleteGroup {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	invMask := mask.setInvert(mask)
+
+	out := make(map[PartyID][][]string, len(group))
+	for _, ge := range group {
+		keyp := &sc.key.setDecrypt(r.recvSK.bsk, &ge.CValKey).m // as in oprfUnblind
+		if r.anchor == hiddenOrigin || ge.Origin != r.anchor {  // the anchor rows are not blinded
+			keyp.setMul(keyp, invMask)
 		}
 
-		out[sourceID] = string(plantext_data)
+		sourceIndex, vals, err := r.decryptRow(ge, keyp, sc)
+		if err != nil && err != errDummyValue {
+			return nil, r.rowError(ge, ge.Origin, fmt.Errorf("undecryptable row: %w", err))
+		}
+		if err == errDummyValue {
+			continue
+		}
+
+		out[r.sourceIDs[sourceIndex]] = append(out[r.sourceIDs[sourceIndex]], vals)
+	}
+	if r.anchor != hiddenOrigin && len(out[r.sourceIDs[r.anchor]]) == 0 { // the anchor rows were dummies
+		return nil, nil
 	}
 	return out, nil
 }
 
-func (r *Receiver) intersectHint(groups map[string][]EncRowWithHint) (JoinTable, error) {
+// verifyGroup verifies the proofs of the rows of a group in one batch, before their decryption. The hints are
+// proven against the share commitment of their origin in threshold sessions, against the pad key commitment
+// for the anchor rows of left joins, and against all the share commitments for the rows with hidden origins,
+// whose proofs do not reveal the origins. The hints of the other rows of left joins are not used, and not
+// proven. The value keys are proven against the pad key commitment, except for the anchor rows of left joins,
+// which are not blinded.
+func (r *Receiver) verifyGroup(group []EncRowWithHint) error {
+	statements, rows := make([]statement, 0, 2*len(group)), make([]int, 0, 2*len(group))
+	unblinded := identity() // the commitment to the zero key of the value keys of the anchor rows
+	for i := range group {
+		ge := &group[i]
+		hint := hintStatement{cnyme: &ge.Cnyme, hint: &ge.CHint, proof: ge.Proof}
+		vkey := valueKeyStatement{keyCom: r.keyComs.pad, cnyme: &ge.Cnyme, vkey: &ge.CValKey, proof: ge.KeyProof}
+		switch {
+		case r.threshold > 0:
+			if ge.Origin < 0 || ge.Origin >= len(r.keyComs.shares) {
+				return r.rowError(*ge, ge.Origin, fmt.Errorf("%w: invalid origin", ErrInvalidProof))
+			}
+			hint.keyComs = r.keyComs.shares[ge.Origin : ge.Origin+1]
+		case r.anchor != hiddenOrigin && ge.Origin == r.anchor:
+			hint.keyComs, vkey.keyCom = []*point{r.keyComs.pad}, unblinded
+		case r.anchor != hiddenOrigin:
+			statements, rows = append(statements, vkey), append(rows, i)
+			continue
+		default:
+			hint.keyComs = r.keyComs.shares
+		}
+		statements, rows = append(statements, hint, vkey), append(rows, i, i)
+	}
+	if i := verifyProofs(r.sid, r.recvPK.bpk, statements); i != -1 {
+		ge := group[rows[i]]
+		what := "hint"
+		if _, ok := statements[i].(valueKeyStatement); ok {
+			what = "value key"
+		}
+		return r.rowError(ge, ge.Origin, fmt.Errorf("%w: %s", ErrInvalidProof, what))
+	}
+	return nil
+}
+
+// decryptRow decrypts the values of a row, given the point from which its symmetric key is derived.
+// It returns the index of the row's source along with the values.
+func (r *Receiver) decryptRow(ge EncRowWithHint, keyp *point, sc *decryptScratch) (int, []string, error) {
+	key, err := sc.kdf.key(keyp, r.sid, helperKeyInfo)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sc.ad = appendPayloadAD(sc.ad[:0], r.sid, ge.Origin)
+	sc.buf, err = appendSymmetricDecrypt(sc.buf[:0], key, ge.CVal, sc.ad)
+	sc.kdf.clear()
+	if err != nil {
+		return 0, nil, err
+	}
+	encAttridValBytes := sc.buf
+
+	if len(encAttridValBytes) < originSize {
+		return 0, nil, fmt.Errorf("incorrect encrypted attribute value")
+	}
+
+	sourceIndex, encValBytes := int(binary.BigEndian.Uint16(encAttridValBytes)), encAttridValBytes[originSize:]
+	if sourceIndex >= len(r.sourceIDs) {
+		return 0, nil, fmt.Errorf("invalid source index: %d", sourceIndex)
+	}
+	if ge.Origin != hiddenOrigin && sourceIndex != ge.Origin {
+		return 0, nil, fmt.Errorf("source index %d does not match the row origin %d", sourceIndex, ge.Origin)
+	}
+
+	sc.values, err = deserializeEncValuesInto(sc.values, encValBytes)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	vals := make([]string, len(sc.values))
+	for i := range sc.values {
+		plantext_data, err := decryptValueInto(&sc.msg, &sc.kdf, r.recvSK.esk, r.sid, &sc.values[i])
+		if err != nil {
+			return sourceIndex, nil, err
+		}
+		vals[i] = string(plantext_data)
+	}
+
+	return sourceIndex, vals, nil
+}
+
+// intersectHint decrypts the complete groups with n workers, which buffer their decrypted rows until they are
+// all inserted in the joined table.
+func (r *Receiver) intersectHint(ctx context.Context, groups groupFeed, n int) (JoinTable, error) {
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	decryptTasks := make(chan []EncRowWithHint)
 
-	join := NewJoinTable(r.sourceIDs)
-	mu := sync.Mutex{}
+	buffers := make([][]map[PartyID][][]string, n)
 
 	wg := sync.WaitGroup{}
-	for range runtime.NumCPU() {
+	for w := range n {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
+			var sc decryptScratch
 			for dectask := range decryptTasks {
-				vals, err := r.decryptGroup(dectask)
+				vals, err := r.decryptGroup(dectask, &sc)
 				if err != nil {
-					panic(err)
+					cancel(err)
+					return
 				}
-				mu.Lock()
-				if err := join.Insert(vals); err != nil {
-					panic(err)
+				if vals == nil {
+					continue
 				}
-				mu.Unlock()
+				buffers[w] = append(buffers[w], vals)
 			}
 		}()
 	}
 
-	for _, group := range groups {
-		if len(group) == len(r.sourceIDs) {
-			decryptTasks <- group
+	err := groups(func(group []EncRowWithHint) bool {
+		if !r.isComplete(group) {
+			return true
 		}
+		select {
+		case decryptTasks <- group:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	if err != nil {
+		cancel(err)
 	}
 	close(decryptTasks)
 
 	wg.Wait()
 
+	if err := context.Cause(ctx); err != nil {
+		return JoinTable{}, err
+	}
+
+	join := NewJoinTableWithColumns(r.sourceIDs, r.columns)
+	for _, buffer := range buffers {
+		for _, vals := range buffer {
+			if err := join.InsertProduct(vals); err != nil {
+				return JoinTable{}, err
+			}
+		}
+	}
 	return join, nil
 }
@@ -0,0 +1,128 @@
+package mppj
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// randomnessPool holds precomputed encryption randomness under a public key, see pkePair. The pairs are
+// removed from the pool when they are used, so that each pair encrypts a single message.
+type randomnessPool struct {
+	pk *publicKey
+
+	mu    sync.Mutex
+	pairs []pkePair
+}
+
+func newRandomnessPool(pk *publicKey) *randomnessPool {
+	return &randomnessPool{pk: pk}
+}
+
+// encrypt encrypts msg with a pair of the pool, or with fresh randomness if the pool is empty.
+func (p *randomnessPool) encrypt(msg *message) (Ciphertext, *scalar) {
+	pair, ok := p.pop()
+	if !ok {
+		return p.pk.encrypt(msg)
+	}
+	return pair.encrypt(msg), pair.r
+}
+
+// pop removes a pair from the pool.
+func (p *randomnessPool) pop() (pkePair, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.pairs)
+	if n == 0 {
+		return pkePair{}, false
+	}
+	pair := p.pairs[n-1]
+	p.pairs[n-1] = pkePair{}
+	p.pairs = p.pairs[:n-1]
+	return pair, true
+}
+
+// push adds pairs to the pool.
+func (p *randomnessPool) push(pairs ...pkePair) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pairs = append(p.pairs, pairs...)
+}
+
+// take removes all the pairs from the pool, and returns them.
+func (p *randomnessPool) take() []pkePair {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pairs := p.pairs
+	p.pairs = nil
+	return pairs
+}
+
+// size returns the number of pairs in the pool.
+func (p *randomnessPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pairs)
+}
+
+// marshalPools serializes the pairs of the UID and value pools, as the number of pairs of each pool, the
+// keys of the pools, the points of the pairs and their randomness.
+func marshalPools(uidKey, valueKey *publicKey, uidPairs, valuePairs []pkePair) ([]byte, error) {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(uidPairs)))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(valuePairs)))
+	buf, err := appendPoints(buf, &uidKey.point, &valueKey.point)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := slices.Concat(uidPairs, valuePairs)
+	for _, pair := range pairs {
+		if buf, err = appendPoints(buf, pair.gr, pair.pkr); err != nil {
+			return nil, err
+		}
+	}
+	for _, pair := range pairs {
+		if buf, err = appendScalars(buf, pair.r); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// unmarshalPools deserializes the pairs of the UID and value pools, which must have been computed for the
+// given keys.
+func unmarshalPools(data []byte, uidKey, valueKey *publicKey) (uidPairs, valuePairs []pkePair, err error) {
+	pointLen, scalarLen := int(group.Params().CompressedElementLength), int(group.Params().ScalarLength)
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("invalid byte slice length for deserialization of randomness pool")
+	}
+	nUID, nValue := int(binary.BigEndian.Uint32(data)), int(binary.BigEndian.Uint32(data[4:]))
+	n := nUID + nValue
+	if n > len(data) || len(data) != 8+2*pointLen+n*(2*pointLen+scalarLen) {
+		return nil, nil, fmt.Errorf("invalid byte slice length for deserialization of randomness pool")
+	}
+	data = data[8:]
+
+	keys, data, err := readPoints(data, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !keys[0].Equals(&uidKey.point) || !keys[1].Equals(&valueKey.point) {
+		return nil, nil, fmt.Errorf("randomness pool computed for other receiver keys")
+	}
+
+	pts, data, err := readPoints(data, 2*n)
+	if err != nil {
+		return nil, nil, err
+	}
+	rs, err := readScalars(data, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	pairs := make([]pkePair, n)
+	for i := range pairs {
+		pairs[i] = pkePair{r: rs[i], gr: pts[2*i], pkr: pts[2*i+1]}
+	}
+	return pairs[:nUID], pairs[nUID:], nil
+}
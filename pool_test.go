@@ -0,0 +1,100 @@
+package mppj
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPPJPrecomputed(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithSourceProofs())
+	require.NoError(t, err, "NewSession() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	seen := make(map[string]struct{})
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		ds := NewDataSource(sess, WithSourceID(sourceID), WithPadding(PadToSize(4)))
+		require.NoError(t, ds.Precompute(context.Background(), 3, 1)) // one row short of the padded table
+		uids, values := ds.PoolSize()
+		require.Equal(t, 3, uids)
+		require.Equal(t, 3, values)
+
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		uids, values = ds.PoolSize()
+		require.Zero(t, uids)
+		require.Zero(t, values)
+
+		for _, row := range prepTable { // every pair is used once
+			for _, ct := range []Ciphertext{row.Cuid, row.Cval[0].CKey} {
+				b, err := ct.c0.MarshalBinary()
+				require.NoError(t, err)
+				require.NotContains(t, seen, string(b))
+				seen[string(b)] = struct{}{}
+			}
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	require.NoError(t, err, "Convert() error")
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+}
+
+func TestSaveLoadPool(t *testing.T) {
+	rsk, rpk := KeyGen()
+	sess, err := NewSession([]PartyID{"ds1", "ds2"}, "helper", "receiver", rpk)
+	require.NoError(t, err, "NewSession() error")
+	path := filepath.Join(t.TempDir(), "pool")
+
+	ds := NewDataSource(sess)
+	require.NoError(t, ds.Precompute(context.Background(), 2, 3, 2))
+	require.NoError(t, ds.SavePool(path))
+	uids, values := ds.PoolSize()
+	require.Zero(t, uids+values, "the saved pairs must leave the memory")
+	require.Error(t, ds.SavePool(path), "expected an error for an existing file")
+
+	loaded := NewDataSource(sess)
+	require.NoError(t, loaded.LoadPool(path))
+	uids, values = loaded.PoolSize()
+	require.Equal(t, 2, uids)
+	require.Equal(t, 6, values)
+	require.Error(t, loaded.LoadPool(path), "expected an error for a file loaded twice")
+
+	row, err := loaded.ProcessRow("a", "1a", "2a", "3a")
+	require.NoError(t, err, "ProcessRow() error")
+	require.True(t, decryptPKE(rsk.bsk, &row.Cuid).m.Equals(&hashToMessage([]byte("a"), sess.ID).m))
+	val, err := decryptValuePKE(rsk.esk, sess.ID, &row.Cval[2])
+	require.NoError(t, err)
+	require.Equal(t, "3a", string(val))
+
+	// pairs computed for other receiver keys
+	_, otherPK := KeyGen()
+	other, err := NewSession([]PartyID{"ds1", "ds2"}, "helper", "receiver", otherPK)
+	require.NoError(t, err, "NewSession() error")
+	require.NoError(t, loaded.SavePool(path))
+	require.Error(t, NewDataSource(other).LoadPool(path))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, ds.Precompute(ctx, 100, 1), context.Canceled)
+}
@@ -9,7 +9,7 @@ func oprfKeyGen() *oprfKey {
 }
 
 // oprfBlind computes the encryption of m using the public key bpk.
-func oprfBlind(bpk *publicKey, msg, sid []byte) *Ciphertext {
+func oprfBlind(bpk *publicKey, msg, sid []byte) Ciphertext {
 	hmsg := hashToMessage(msg, sid)
 	return encryptPKE(bpk, hmsg)
 }
@@ -20,9 +20,17 @@ func oprfUnblind(bsk *secretKey, ciphertext *Ciphertext) *message {
 }
 
 // oprfEval computes the encryption of m^k. Computes ReRand internally.
-func oprfEval(key *oprfKey, bpk *publicKey, ciphertext *Ciphertext) *Ciphertext {
-	c0 := ciphertext.c0.scalarExp((*scalar)(key))
-	c1 := ciphertext.c1.scalarExp((*scalar)(key))
+func oprfEval(key *oprfKey, bpk *publicKey, ciphertext *Ciphertext) Ciphertext {
+	var out Ciphertext
+	out.setOPRFEval(key, bpk, ciphertext, randomScalar(), new(point))
+	return out
+}
+
+// setOPRFEval is the in-place variant of oprfEval with the re-randomization r, which sets out to the
+// evaluation. The ciphertext must not alias out, and t is a scratch point of the caller.
+func (out *Ciphertext) setOPRFEval(key *oprfKey, bpk *publicKey, ciphertext *Ciphertext, r *scalar, t *point) *Ciphertext {
+	out.c0.setScalarExp(&ciphertext.c0, (*scalar)(key))
+	out.c1.setScalarExp(&ciphertext.c1, (*scalar)(key))
 
-	return reRand(bpk, &Ciphertext{c0: c0, c1: c1})
+	return out.setReRand(bpk, out, r, t)
 }
@@ -0,0 +1,547 @@
+package mppj
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// ErrInvalidProof is returned by the helper when a row of a source comes without a valid proof of knowledge
+// of its randomness, and by the receiver when a row of the helper is not consistent with the helper's key
+// commitments.
+var ErrInvalidProof = errors.New("invalid proof")
+
+const (
+	hintProofDST     = "mppj hint proof"
+	valueKeyProofDST = "mppj value key proof"
+	sourceProofDST   = "mppj source proof"
+)
+
+// HintProof is a non-interactive proof that a hint is a rerandomized exponentiation of a pseudonym ciphertext
+// by one of a list of committed keys, without revealing which. For a key s with commitment K = g^s, the
+// pseudonym ciphertext C and the hint ciphertext H, each branch of the proof is a Chaum-Pedersen proof of
+// knowledge of (s, r) such that
+//
+//	K = g^s,  H.c0 = C.c0^s * g^r,  H.c1 = C.c1^s * bpk^r.
+//
+// The branches are combined into a disjunction as in Cramer, Damgård and Schoenmakers (CRYPTO 1994): the
+// challenges of the branches sum to the Fiat-Shamir challenge, so that the helper can simulate all the
+// branches but the one of its key. The hints of the rows with hidden origins are proven against all the share
+// commitments, with one branch per source, so that the proofs do not reveal the origins of the rows to the
+// receiver. The other hints are proven against a single commitment.
+//
+// The proof holds its commitments along with its challenges, so that proofs can be verified in batches.
+type HintProof struct {
+	branches []hintBranch
+}
+
+// hintBranch is the branch of a HintProof for one of the key commitments.
+type hintBranch struct {
+	a0, a1, a2 *point
+	c, zs, zr  *scalar
+}
+
+// hintBranchSize is the size in bytes of a serialized branch of a HintProof.
+var hintBranchSize = 3*int(group.Params().CompressedElementLength) + 3*int(group.Params().ScalarLength)
+
+// ValueKeyProof is a non-interactive proof that the value key of a row is blinded with its pseudonym raised to
+// the committed pad key. For the pad key s with commitment K = g^s, the pseudonym ciphertext C and the value
+// key ciphertext V, it proves knowledge of (s, r, p) such that
+//
+//	K = g^s,  V.c0 = C.c0^s * g^r,  V.c1 = C.c1^s * bpk^r * g^p,
+//
+// that is, V encrypts joinid^s * g^p, where g^p is the point from which the payload key of the row is derived.
+// The value keys of the anchor rows of left joins, which are not blinded, are proven with the identity as K.
+type ValueKeyProof struct {
+	a0, a1, a2 *point
+	zs, zr, zp *scalar
+}
+
+// valueKeyProofSize is the size in bytes of a serialized ValueKeyProof.
+var valueKeyProofSize = 3*int(group.Params().CompressedElementLength) + 3*int(group.Params().ScalarLength)
+
+// SourceProof is a non-interactive Schnorr proof of knowledge of the randomness r of a UID ciphertext
+// C = (g^r, H(uid) * bpk^r), bound to the session and to the PartyID of the source. As re-randomizing the
+// ciphertext of another source does not reveal its randomness, it prevents a source from submitting copies
+// of the rows of other sources as its own.
+type SourceProof struct {
+	a *point
+	z *scalar
+}
+
+// sourceProofSize is the size in bytes of a serialized SourceProof.
+var sourceProofSize = int(group.Params().CompressedElementLength) + int(group.Params().ScalarLength)
+
+// proveSource computes the proof of knowledge of the randomness r of cuid for the given session and source.
+func proveSource(sid []byte, source PartyID, bpk *publicKey, cuid *Ciphertext, r *scalar) *SourceProof {
+	t := randomScalar()
+	proof := &SourceProof{a: baseExp(t)}
+	c := sourceChallenge(sid, source, bpk, cuid, proof.a)
+	proof.z = t.add(c.mul(r))
+	return proof
+}
+
+// verifySource checks the proof of knowledge of the randomness of cuid for the given session and source.
+func verifySource(sid []byte, source PartyID, bpk *publicKey, cuid *Ciphertext, proof *SourceProof) bool {
+	if proof == nil {
+		return false
+	}
+	c := sourceChallenge(sid, source, bpk, cuid, proof.a)
+	return baseExp(proof.z).Equals(mul(proof.a, cuid.c0.scalarExp(c)))
+}
+
+// sourceChallenge computes the Fiat-Shamir challenge of a source proof. The source ID is length-prefixed so
+// that the transcript is parsed unambiguously.
+func sourceChallenge(sid []byte, source PartyID, bpk *publicKey, cuid *Ciphertext, a *point) *scalar {
+	transcript := binary.BigEndian.AppendUint32(slices.Clone(sid), uint32(len(source)))
+	transcript = append(transcript, source...)
+	for _, p := range []*point{&bpk.point, &cuid.c0, &cuid.c1, a} {
+		b, _ := p.MarshalBinary()
+		transcript = append(transcript, b...)
+	}
+	return &scalar{s: group.HashToScalar(transcript, []byte(sourceProofDST))}
+}
+
+// MarshalBinary serializes a SourceProof into a byte slice.
+func (p *SourceProof) MarshalBinary() ([]byte, error) {
+	buf, err := p.a.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != int(group.Params().CompressedElementLength) {
+		return nil, fmt.Errorf("invalid proof commitment")
+	}
+	z, err := p.z.s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, z...), nil
+}
+
+// UnmarshalBinary deserializes a byte slice into a SourceProof.
+func (p *SourceProof) UnmarshalBinary(data []byte) error {
+	if len(data) != sourceProofSize {
+		return fmt.Errorf("invalid byte slice length for deserialization of proof")
+	}
+	pointLen := int(group.Params().CompressedElementLength)
+	a := newPoint()
+	if err := a.UnmarshalBinary(data[:pointLen]); err != nil {
+		return err
+	}
+	z := &scalar{s: group.NewScalar()}
+	if err := z.s.UnmarshalBinary(data[pointLen:]); err != nil {
+		return err
+	}
+	*p = SourceProof{a: a, z: z}
+	return nil
+}
+
+// KeyCommitments holds the helper's public commitments g^k to its conversion key, to its pad key and to the
+// pad key shares of the sources. The receiver uses the commitments to the pad key and to its shares to verify
+// the proofs of the rows, and an auditor uses the commitments to the conversion key and to the pad key to
+// verify the pseudonyms and the value keys of a shuffle transcript.
+type KeyCommitments struct {
+	conv   *point
+	pad    *point
+	shares []*point
+}
+
+// oprfEvalWithProof computes oprfEval(key, bpk, ct) along with a proof of correct evaluation with respect to
+// the commitment keyCom = g^key.
+func oprfEvalWithProof(key *oprfKey, keyCom *point, bpk *publicKey, ct *Ciphertext, sid []byte) (Ciphertext, *HintProof) {
+	var out Ciphertext
+	proof := out.setOPRFEvalWithProof(key, []*point{keyCom}, 0, bpk, ct, sid, new(point))
+	return out, proof
+}
+
+// setOPRFEvalWithProof is the in-place variant of oprfEvalWithProof, which sets out to the evaluation and
+// returns its proof with respect to the commitments keyComs, among which keyComs[index] is g^key. The branches
+// of the other commitments are simulated. The ciphertext ct must not alias out, and tmp is a scratch point of
+// the caller.
+func (out *Ciphertext) setOPRFEvalWithProof(key *oprfKey, keyComs []*point, index int, bpk *publicKey, ct *Ciphertext, sid []byte, tmp *point) *HintProof {
+	r := randomScalar()
+	out.setOPRFEval(key, bpk, ct, r, tmp)
+	return proveOPRFEval(key, r, keyComs, index, bpk, ct, out, sid, tmp)
+}
+
+// proveOPRFEval returns the proof that out = (ct.c0^key * g^r, ct.c1^key * bpk^r), as computed by setOPRFEval,
+// with respect to the commitments keyComs, among which keyComs[index] is g^key.
+func proveOPRFEval(key *oprfKey, r *scalar, keyComs []*point, index int, bpk *publicKey, ct, out *Ciphertext, sid []byte, tmp *point) *HintProof {
+	s := (*scalar)(key)
+
+	proof := &HintProof{branches: make([]hintBranch, len(keyComs))}
+	sum := newScalar(big.NewInt(0))
+	for j, keyCom := range keyComs {
+		if j == index {
+			continue
+		}
+		// a0 = g^zs / K^c, a1 = C.c0^zs * g^zr / H.c0^c, a2 = C.c1^zs * bpk^zr / H.c1^c
+		br := &proof.branches[j]
+		br.c, br.zs, br.zr = randomScalar(), randomScalar(), randomScalar()
+		negC := br.c.neg()
+		br.a0 = mul(baseExp(br.zs), keyCom.scalarExp(negC))
+		br.a1 = mul(mul(ct.c0.scalarExp(br.zs), baseExp(br.zr)), out.c0.scalarExp(negC))
+		br.a2 = mul(mul(ct.c1.scalarExp(br.zs), bpk.exp(br.zr)), out.c1.scalarExp(negC))
+		sum = sum.add(br.c)
+	}
+
+	t, u := randomScalar(), randomScalar()
+	br := &proof.branches[index]
+	br.a0, br.a1, br.a2 = baseExp(t), ct.c0.scalarExp(t), ct.c1.scalarExp(t)
+	br.a1.setMul(br.a1, tmp.setBaseExp(u))
+	br.a2.setMul(br.a2, bpk.expInto(tmp, u))
+	br.c = hintChallenge(sid, bpk, keyComs, ct, out, proof).sub(sum)
+	br.zs = t.add(br.c.mul(s))
+	br.zr = u.add(br.c.mul(r))
+	return proof
+}
+
+// hintChallenge computes the Fiat-Shamir challenge of a hint proof, which is the sum of the challenges of its
+// branches.
+func hintChallenge(sid []byte, bpk *publicKey, keyComs []*point, ct, hint *Ciphertext, proof *HintProof) *scalar {
+	points := append(make([]*point, 0, 5+4*len(keyComs)), &bpk.point, &ct.c0, &ct.c1, &hint.c0, &hint.c1)
+	points = append(points, keyComs...)
+	for _, br := range proof.branches {
+		points = append(points, br.a0, br.a1, br.a2)
+	}
+	return challenge(sid, hintProofDST, points)
+}
+
+// proveValueKey returns the proof that vkey = (C.c0^key * g^r, C.c1^key * bpk^r * g^p) for the pseudonym
+// ciphertext cnyme = C and the commitment keyCom = g^key. The point tmp is a scratch point of the caller.
+func proveValueKey(key *scalar, keyCom *point, bpk *publicKey, cnyme, vkey *Ciphertext, r, p *scalar, sid []byte, tmp *point) *ValueKeyProof {
+	t, u, v := randomScalar(), randomScalar(), randomScalar()
+	proof := &ValueKeyProof{a0: baseExp(t), a1: cnyme.c0.scalarExp(t), a2: cnyme.c1.scalarExp(t)}
+	proof.a1.setMul(proof.a1, tmp.setBaseExp(u))
+	proof.a2.setMul(proof.a2, bpk.expInto(tmp, u))
+	proof.a2.setMul(proof.a2, tmp.setBaseExp(v))
+	c := valueKeyChallenge(sid, bpk, keyCom, cnyme, vkey, proof)
+	proof.zs, proof.zr, proof.zp = t.add(c.mul(key)), u.add(c.mul(r)), v.add(c.mul(p))
+	return proof
+}
+
+// valueKeyChallenge computes the Fiat-Shamir challenge of a value key proof.
+func valueKeyChallenge(sid []byte, bpk *publicKey, keyCom *point, cnyme, vkey *Ciphertext, proof *ValueKeyProof) *scalar {
+	return challenge(sid, valueKeyProofDST, []*point{&bpk.point, keyCom, &cnyme.c0, &cnyme.c1, &vkey.c0, &vkey.c1, proof.a0, proof.a1, proof.a2})
+}
+
+// challenge hashes the session ID and the points of the transcript of a proof into a challenge.
+func challenge(sid []byte, dst string, points []*point) *scalar {
+	transcript := append(make([]byte, 0, len(sid)+len(points)*ciphertextSize/2), sid...)
+	for _, p := range points {
+		b, _ := p.MarshalBinary()
+		transcript = append(transcript, b...)
+	}
+	return &scalar{s: group.HashToScalar(transcript, []byte(dst))}
+}
+
+// batchVerifier verifies the equations of a batch of proofs at once, by checking that a random linear
+// combination of the equations holds with a single multi-exponentiation. Each equation is written as a product
+// of powers that equals the identity, and is weighted with a random scalar. A false equation makes the
+// combination hold only with negligible probability over the weights.
+type batchVerifier struct {
+	g     *point
+	bases []*point
+	exps  []*scalar
+	index map[*point]int // the positions of the bases, whose exponents are summed if they occur several times
+}
+
+// batchWeightSize is the size in bytes of the weights of the equations of a batch, whose soundness error is
+// 2^-(8*batchWeightSize) per batch. The shorter weights save the additions of the upper digits of the
+// exponents of the proof commitments.
+const batchWeightSize = 16
+
+// randomWeight returns a random weight for an equation of a batch.
+func randomWeight() *scalar {
+	var w [batchWeightSize]byte
+	if _, err := rand.Read(w[:]); err != nil {
+		panic(err)
+	}
+	return newScalar(new(big.Int).SetBytes(w[:]))
+}
+
+func newBatchVerifier() *batchVerifier {
+	return &batchVerifier{g: gen(), index: make(map[*point]int)}
+}
+
+// add adds base^e to the combination.
+func (b *batchVerifier) add(base *point, e *scalar) {
+	if i, ok := b.index[base]; ok {
+		b.exps[i] = b.exps[i].add(e)
+		return
+	}
+	b.index[base] = len(b.bases)
+	b.bases, b.exps = append(b.bases, base), append(b.exps, e)
+}
+
+// valid returns whether the combination equals the identity.
+func (b *batchVerifier) valid() bool {
+	return multiExp(b.bases, b.exps).Equals(identity())
+}
+
+// statement is the statement of a proof of the helper.
+type statement interface {
+	// addTo adds the verification equations of the proof to the batch, or returns false if the proof is
+	// missing or malformed.
+	addTo(b *batchVerifier, sid []byte, bpk *publicKey) bool
+}
+
+// hintStatement is the statement of a hint proof: the hint is the pseudonym raised to one of the committed keys.
+type hintStatement struct {
+	keyComs []*point
+	cnyme   *Ciphertext
+	hint    *Ciphertext
+	proof   *HintProof
+}
+
+func (st hintStatement) addTo(b *batchVerifier, sid []byte, bpk *publicKey) bool {
+	p := st.proof
+	if p == nil || len(p.branches) != len(st.keyComs) {
+		return false
+	}
+	sum := newScalar(big.NewInt(0))
+	for _, br := range p.branches {
+		sum = sum.add(br.c)
+	}
+	if !sum.Equals(hintChallenge(sid, bpk, st.keyComs, st.cnyme, st.hint, p)) {
+		return false
+	}
+	for j, br := range p.branches {
+		w0, w1, w2 := randomWeight(), randomWeight(), randomWeight()
+
+		// g^zs / (a0 * K^c) = 1
+		b.add(b.g, w0.mul(br.zs))
+		b.add(br.a0, w0.neg())
+		b.add(st.keyComs[j], w0.mul(br.c).neg())
+
+		// C.c0^zs * g^zr / (a1 * H.c0^c) = 1
+		b.add(&st.cnyme.c0, w1.mul(br.zs))
+		b.add(b.g, w1.mul(br.zr))
+		b.add(br.a1, w1.neg())
+		b.add(&st.hint.c0, w1.mul(br.c).neg())
+
+		// C.c1^zs * bpk^zr / (a2 * H.c1^c) = 1
+		b.add(&st.cnyme.c1, w2.mul(br.zs))
+		b.add(&bpk.point, w2.mul(br.zr))
+		b.add(br.a2, w2.neg())
+		b.add(&st.hint.c1, w2.mul(br.c).neg())
+	}
+	return true
+}
+
+// valueKeyStatement is the statement of a value key proof: the value key is blinded with the pseudonym raised
+// to the committed key.
+type valueKeyStatement struct {
+	keyCom *point
+	cnyme  *Ciphertext
+	vkey   *Ciphertext
+	proof  *ValueKeyProof
+}
+
+func (st valueKeyStatement) addTo(b *batchVerifier, sid []byte, bpk *publicKey) bool {
+	p := st.proof
+	if p == nil {
+		return false
+	}
+	c := valueKeyChallenge(sid, bpk, st.keyCom, st.cnyme, st.vkey, p)
+	w0, w1, w2 := randomWeight(), randomWeight(), randomWeight()
+
+	// g^zs / (a0 * K^c) = 1
+	b.add(b.g, w0.mul(p.zs))
+	b.add(p.a0, w0.neg())
+	b.add(st.keyCom, w0.mul(c).neg())
+
+	// C.c0^zs * g^zr / (a1 * V.c0^c) = 1
+	b.add(&st.cnyme.c0, w1.mul(p.zs))
+	b.add(b.g, w1.mul(p.zr))
+	b.add(p.a1, w1.neg())
+	b.add(&st.vkey.c0, w1.mul(c).neg())
+
+	// C.c1^zs * bpk^zr * g^zp / (a2 * V.c1^c) = 1
+	b.add(&st.cnyme.c1, w2.mul(p.zs))
+	b.add(&bpk.point, w2.mul(p.zr))
+	b.add(b.g, w2.mul(p.zp))
+	b.add(p.a2, w2.neg())
+	b.add(&st.vkey.c1, w2.mul(c).neg())
+	return true
+}
+
+// verifyProofs verifies the proofs of a batch of statements at once, with a batchVerifier. It returns the index
+// of an invalid statement, or -1 if all are valid. The statements of an invalid batch are verified one by one,
+// to locate an invalid one.
+func verifyProofs(sid []byte, bpk *publicKey, statements []statement) int {
+	if len(statements) < 2 {
+		return verifyEach(sid, bpk, statements)
+	}
+	b := newBatchVerifier()
+	for _, st := range statements {
+		if !st.addTo(b, sid, bpk) {
+			return verifyEach(sid, bpk, statements)
+		}
+	}
+	if b.valid() {
+		return -1
+	}
+	return verifyEach(sid, bpk, statements)
+}
+
+// verifyEach verifies the proofs one by one, and returns the index of the first invalid statement, or -1.
+func verifyEach(sid []byte, bpk *publicKey, statements []statement) int {
+	for i, st := range statements {
+		b := newBatchVerifier()
+		if !st.addTo(b, sid, bpk) || !b.valid() {
+			return i
+		}
+	}
+	return -1
+}
+
+// MarshalBinary serializes a HintProof into a byte slice, as the number of its branches on two bytes followed
+// by the branches.
+func (p *HintProof) MarshalBinary() ([]byte, error) {
+	buf := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(p.branches)*hintBranchSize), uint16(len(p.branches)))
+	for _, br := range p.branches {
+		var err error
+		if buf, err = appendPoints(buf, br.a0, br.a1, br.a2); err != nil {
+			return nil, err
+		}
+		if buf, err = appendScalars(buf, br.c, br.zs, br.zr); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// hintProofLen returns the length of the serialized HintProof at the start of data, or -1 if data is too short.
+func hintProofLen(data []byte) int {
+	if len(data) < 2 {
+		return -1
+	}
+	if n := 2 + int(binary.BigEndian.Uint16(data))*hintBranchSize; n <= len(data) {
+		return n
+	}
+	return -1
+}
+
+// UnmarshalBinary deserializes a byte slice into a HintProof.
+func (p *HintProof) UnmarshalBinary(data []byte) error {
+	if hintProofLen(data) != len(data) || len(data) == 2 {
+		return fmt.Errorf("invalid byte slice length for deserialization of proof")
+	}
+	branches := make([]hintBranch, binary.BigEndian.Uint16(data))
+	data = data[2:]
+	for i := range branches {
+		pts, rest, err := readPoints(data[:hintBranchSize], 3)
+		if err != nil {
+			return err
+		}
+		scs, err := readScalars(rest, 3)
+		if err != nil {
+			return err
+		}
+		branches[i] = hintBranch{a0: pts[0], a1: pts[1], a2: pts[2], c: scs[0], zs: scs[1], zr: scs[2]}
+		data = data[hintBranchSize:]
+	}
+	*p = HintProof{branches: branches}
+	return nil
+}
+
+// MarshalBinary serializes a ValueKeyProof into a byte slice.
+func (p *ValueKeyProof) MarshalBinary() ([]byte, error) {
+	buf, err := appendPoints(make([]byte, 0, valueKeyProofSize), p.a0, p.a1, p.a2)
+	if err != nil {
+		return nil, err
+	}
+	return appendScalars(buf, p.zs, p.zr, p.zp)
+}
+
+// UnmarshalBinary deserializes a byte slice into a ValueKeyProof.
+func (p *ValueKeyProof) UnmarshalBinary(data []byte) error {
+	if len(data) != valueKeyProofSize {
+		return fmt.Errorf("invalid byte slice length for deserialization of proof")
+	}
+	pts, rest, err := readPoints(data, 3)
+	if err != nil {
+		return err
+	}
+	scs, err := readScalars(rest, 3)
+	if err != nil {
+		return err
+	}
+	*p = ValueKeyProof{a0: pts[0], a1: pts[1], a2: pts[2], zs: scs[0], zr: scs[1], zp: scs[2]}
+	return nil
+}
+
+// commitments returns the commitments to the helper's keys.
+func (h *Helper) commitments() KeyCommitments {
+	com := KeyCommitments{conv: baseExp((*scalar)(h.convK)), pad: baseExp(h.padKey), shares: make([]*point, len(h.padKeyShares))}
+	for i, share := range h.padKeyShares {
+		com.shares[i] = baseExp(share)
+	}
+	return com
+}
+
+// Commitments returns the helper's key commitments, to be sent to the receiver in sessions with helper proofs,
+// and to the auditors of shuffle transcripts. The commitments do not reveal anything about the joined tables.
+func (h *Helper) Commitments() KeyCommitments {
+	return h.keyComs
+}
+
+// check verifies that the commitments are consistent with the pad key of the session: the pad key is the
+// sum of the shares, or the value at zero of the polynomial interpolating the shares in threshold sessions.
+func (com KeyCommitments) check(numSources, threshold int) error {
+	if com.conv == nil || com.pad == nil || len(com.shares) != numSources {
+		return fmt.Errorf("expected commitments for %d sources, got %d", numSources, len(com.shares))
+	}
+	if threshold == 0 {
+		if !mulBatched(com.shares).Equals(com.pad) {
+			return fmt.Errorf("%w: pad key commitment does not match the shares", ErrInvalidProof)
+		}
+		return nil
+	}
+
+	xs := make([]*scalar, threshold)
+	for i := range xs {
+		xs[i] = newScalar(big.NewInt(int64(i + 1)))
+	}
+	interpolate := func(x *scalar) *point {
+		res := identity()
+		for j := range xs {
+			res = mul(res, com.shares[j].scalarExp(lagrangeCoefficientAt(xs, j, x)))
+		}
+		return res
+	}
+	if !interpolate(newScalar(big.NewInt(0))).Equals(com.pad) {
+		return fmt.Errorf("%w: pad key commitment does not match the shares", ErrInvalidProof)
+	}
+	for i := threshold; i < numSources; i++ {
+		if !interpolate(newScalar(big.NewInt(int64(i + 1)))).Equals(com.shares[i]) {
+			return fmt.Errorf("%w: share commitments are not of degree %d", ErrInvalidProof, threshold-1)
+		}
+	}
+	return nil
+}
+
+// MarshalBinary serializes the commitments into a byte slice, as the commitments to the conversion key and to
+// the pad key followed by the commitments to the shares.
+func (com KeyCommitments) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, (len(com.shares)+2)*int(group.Params().CompressedElementLength))
+	return appendPoints(buf, append([]*point{com.conv, com.pad}, com.shares...)...)
+}
+
+// UnmarshalBinary deserializes a byte slice into commitments.
+func (com *KeyCommitments) UnmarshalBinary(data []byte) error {
+	pointLen := int(group.Params().CompressedElementLength)
+	if len(data) < 2*pointLen || len(data)%pointLen != 0 {
+		return fmt.Errorf("invalid byte slice length for deserialization of commitments")
+	}
+	pts, _, err := readPoints(data, len(data)/pointLen)
+	if err != nil {
+		return err
+	}
+	*com = KeyCommitments{conv: pts[0], pad: pts[1], shares: pts[2:]}
+	return nil
+}
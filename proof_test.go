@@ -0,0 +1,269 @@
+package mppj
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPPJHelperProofs(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	for _, test := range []struct {
+		name     string
+		opts     []SessionOption
+		expected JoinTable
+	}{
+		{"all", nil, IntersectPlain(tables, sourceIDs)},
+		{"threshold", []SessionOption{WithThreshold(2)}, IntersectPlainThreshold(tables, sourceIDs, 2)},
+		{"left", []SessionOption{WithAnchor("ds1")}, IntersectPlainLeft(tables, sourceIDs, "ds1")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rsk, rpk := KeyGen()
+			sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, append(test.opts, WithHelperProofs())...)
+			require.NoError(t, err, "NewSession() error")
+
+			helper := NewHelper(sess)
+			receiver := NewReceiver(sess, rsk)
+			ds := NewDataSource(sess, WithPadding(PadToSize(4)))
+
+			encTables := make(map[PartyID]EncTable, len(tables))
+			for sourceID, table := range tables {
+				prepTable, err := ds.Prepare(table)
+				require.NoError(t, err, "Prepare() error")
+				encTables[sourceID] = prepTable
+			}
+
+			joinedTables, err := helper.Convert(encTables)
+			require.NoError(t, err, "Convert() error")
+
+			// the commitments and proofs go through the wire format
+			comBytes, err := helper.Commitments().MarshalBinary()
+			require.NoError(t, err)
+			var com KeyCommitments
+			require.NoError(t, com.UnmarshalBinary(comBytes))
+			for i, row := range joinedTables {
+				data, err := row.MarshalBinary()
+				require.NoError(t, err)
+				require.NoError(t, joinedTables[i].UnmarshalBinary(data))
+			}
+
+			_, err = receiver.JoinTables(joinedTables)
+			require.Error(t, err, "expected an error without the helper commitments")
+
+			require.NoError(t, receiver.SetHelperCommitments(com))
+			intersection, err := receiver.JoinTables(joinedTables)
+			require.NoError(t, err, "JoinTables() error")
+			if !test.expected.EqualContents(&intersection) {
+				t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", test.expected, intersection)
+			}
+
+			// the hints of the rows with hidden origins are proven against all the share commitments
+			for _, row := range joinedTables {
+				if row.Proof != nil && row.Origin == hiddenOrigin {
+					require.Len(t, row.Proof.branches, len(sourceIDs))
+				}
+			}
+
+			// a tampered row is rejected, if it is part of a matching group
+			rejects := func(tamper func(row *EncRowWithHint)) bool {
+				for i := range joinedTables {
+					tampered := slices.Clone(joinedTables)
+					tamper(&tampered[i])
+					if _, err := receiver.JoinTables(tampered); errors.Is(err, ErrInvalidProof) {
+						return true
+					}
+				}
+				return false
+			}
+			require.True(t, rejects(func(row *EncRowWithHint) {
+				if row.Proof != nil {
+					row.CHint = oprfEval(oprfKeyGen(), rpk.bpk, &row.Cnyme)
+				}
+			}), "expected a hint computed with another key to be rejected")
+			require.True(t, rejects(func(row *EncRowWithHint) {
+				row.CValKey.c1 = *mul(&row.CValKey.c1, randomPoint())
+			}), "expected a value key blinded with another point to be rejected")
+			require.True(t, rejects(func(row *EncRowWithHint) {
+				row.KeyProof = nil
+			}), "expected a row without a value key proof to be rejected")
+		})
+	}
+}
+
+func TestKeyCommitmentsCheck(t *testing.T) {
+	for _, threshold := range []int{0, 2, 3} {
+		h := &Helper{convK: oprfKeyGen()}
+		if threshold > 0 {
+			h.padKeyShares, h.padKey = h.genShares(4, threshold)
+		} else {
+			h.padKeyShares, h.padKey = h.genNonces(4)
+		}
+		com := h.commitments()
+		require.NoError(t, com.check(4, threshold), "threshold %d", threshold)
+
+		com.shares[3] = baseExp(randomScalar())
+		require.ErrorIs(t, com.check(4, threshold), ErrInvalidProof, "threshold %d", threshold)
+
+		com.conv = nil
+		require.Error(t, com.check(4, threshold), "threshold %d", threshold)
+	}
+}
+
+func TestVerifyProofs(t *testing.T) {
+	_, rpk := KeyGen()
+	sid := []byte("session")
+	keys := []*oprfKey{oprfKeyGen(), oprfKeyGen(), oprfKeyGen()}
+	keyComs := make([]*point, len(keys))
+	for i, key := range keys {
+		keyComs[i] = baseExp((*scalar)(key))
+	}
+
+	statements := make([]statement, 9)
+	for i := range statements {
+		cnyme := encryptPKE(rpk.bpk, &message{m: *randomPoint()})
+		var out Ciphertext
+		switch i % 3 {
+		case 0: // a hint proven against one commitment
+			proof := out.setOPRFEvalWithProof(keys[0], keyComs[:1], 0, rpk.bpk, &cnyme, sid, new(point))
+			statements[i] = hintStatement{keyComs: keyComs[:1], cnyme: &cnyme, hint: &out, proof: proof}
+		case 1: // a hint proven against all the commitments
+			proof := out.setOPRFEvalWithProof(keys[i%len(keys)], keyComs, i%len(keys), rpk.bpk, &cnyme, sid, new(point))
+			statements[i] = hintStatement{keyComs: keyComs, cnyme: &cnyme, hint: &out, proof: proof}
+		case 2: // a blinded value key
+			r, p := randomScalar(), randomScalar()
+			out.setOPRFEval(keys[1], rpk.bpk, &cnyme, r, new(point))
+			out.c1.setMul(&out.c1, baseExp(p))
+			proof := proveValueKey((*scalar)(keys[1]), keyComs[1], rpk.bpk, &cnyme, &out, r, p, sid, new(point))
+			statements[i] = valueKeyStatement{keyCom: keyComs[1], cnyme: &cnyme, vkey: &out, proof: proof}
+		}
+	}
+	require.Equal(t, -1, verifyProofs(sid, rpk.bpk, statements))
+	require.Equal(t, 0, verifyProofs([]byte("other session"), rpk.bpk, statements))
+
+	// the proofs go through the wire format
+	for _, st := range statements {
+		switch st := st.(type) {
+		case hintStatement:
+			data, err := st.proof.MarshalBinary()
+			require.NoError(t, err)
+			require.Len(t, data, hintProofLen(data))
+			require.NoError(t, st.proof.UnmarshalBinary(data))
+		case valueKeyStatement:
+			data, err := st.proof.MarshalBinary()
+			require.NoError(t, err)
+			require.NoError(t, st.proof.UnmarshalBinary(data))
+		}
+	}
+	require.Equal(t, -1, verifyProofs(sid, rpk.bpk, statements))
+
+	tampered := func(i int, tamper func(st statement) statement) int {
+		sts := slices.Clone(statements)
+		sts[i] = tamper(sts[i])
+		return verifyProofs(sid, rpk.bpk, sts)
+	}
+	require.Equal(t, 3, tampered(3, func(st statement) statement {
+		hst := st.(hintStatement)
+		rerand := reRand(rpk.bpk, hst.hint)
+		hst.hint = &rerand
+		return hst
+	}), "a re-randomized hint")
+	require.Equal(t, 4, tampered(4, func(st statement) statement {
+		hst := st.(hintStatement)
+		hst.keyComs = []*point{keyComs[0], keyComs[2], baseExp(randomScalar())}
+		return hst
+	}), "a hint proven against other commitments")
+	require.Equal(t, 4, tampered(4, func(st statement) statement {
+		hst := st.(hintStatement)
+		hst.keyComs = keyComs[:2]
+		return hst
+	}), "a hint proven against fewer commitments")
+	require.Equal(t, 5, tampered(5, func(st statement) statement {
+		vst := st.(valueKeyStatement)
+		vkey := *vst.vkey
+		vkey.c1 = *mul(&vkey.c1, randomPoint())
+		vst.vkey = &vkey
+		return vst
+	}), "a value key blinded with another point")
+	require.Equal(t, 8, tampered(8, func(st statement) statement {
+		vst := st.(valueKeyStatement)
+		vst.keyCom = keyComs[0]
+		return vst
+	}), "a value key proven against another commitment")
+	require.Equal(t, 6, tampered(6, func(st statement) statement {
+		hst := st.(hintStatement)
+		hst.proof = nil
+		return hst
+	}), "a missing proof")
+}
+
+func TestMPPJSourceProofs(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithSourceProofs())
+	require.NoError(t, err, "NewSession() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	_, err = NewDataSource(sess).Prepare(tables["ds1"])
+	require.Error(t, err, "expected an error without the source ID")
+	_, err = NewDataSource(sess, WithSourceID("ds1")).ProcessRow("a", "1a")
+	require.Error(t, err, "expected an error from ProcessRow in sessions with source proofs")
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := NewDataSource(sess, WithSourceID(sourceID), WithPadding(PadToSize(4))).Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		for i, row := range prepTable { // the proofs go through the wire format
+			data, err := row.MarshalBinary()
+			require.NoError(t, err)
+			require.NoError(t, prepTable[i].UnmarshalBinary(data))
+			require.NotNil(t, prepTable[i].Proof)
+		}
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, err := helper.Convert(encTables)
+	require.NoError(t, err, "Convert() error")
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+
+	// a row of ds1 re-randomized and submitted by ds2 is rejected, with or without the proof of ds1
+	row := encTables["ds1"][0]
+	copied := row
+	copied.Cuid = reRand(rpk.bpk, &row.Cuid)
+	_, err = helper.ConvertRow(rpk, &copied, "ds2")
+	require.ErrorIs(t, err, ErrInvalidProof)
+	_, err = helper.ConvertRow(rpk, &row, "ds2")
+	require.ErrorIs(t, err, ErrInvalidProof)
+	unproven := row
+	unproven.Proof = nil
+	_, err = helper.ConvertRow(rpk, &unproven, "ds1")
+	require.ErrorIs(t, err, ErrInvalidProof)
+	_, err = helper.ConvertRow(rpk, &row, "ds1")
+	require.NoError(t, err)
+
+	encTables["ds2"] = append(encTables["ds2"], copied)
+	_, err = helper.Convert(encTables)
+	require.ErrorIs(t, err, ErrInvalidProof)
+}
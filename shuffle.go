@@ -0,0 +1,723 @@
+package mppj
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	mrand "math/rand/v2"
+	"slices"
+)
+
+const (
+	shuffleGeneratorsDST = "mppj shuffle generators"
+	shuffleChallengeDST  = "mppj shuffle challenge"
+)
+
+// secureRand returns a pseudorandom generator seeded from crypto/rand, for sampling secret permutations.
+func secureRand() *mrand.Rand {
+	var seed [32]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return mrand.New(mrand.NewChaCha8(seed))
+}
+
+// ShuffleProof is a non-interactive Terelius-Wikström proof that a list of rows of ElGamal ciphertexts is a
+// permutation of re-encryptions of another list. It follows the GenShuffleProof and CheckShuffleProof algorithms
+// of Haenni et al., "Pseudo-Code Algorithms for Verifiable Re-Encryption Mix-Nets" (FC 2017), with the
+// commitments held in the proof instead of the challenge, and extends them to rows of several ciphertexts as in
+// the mix-nets of Wikström: the components of a row are permuted together, under the same commitment to the
+// permutation, and each component is re-encrypted under its own key, with its own commitments t41, t42 and
+// response s4.
+type ShuffleProof struct {
+	coms  []*point // the commitment to the permutation
+	chain []*point // the commitment chain
+
+	t1, t2, t3 *point
+	t41, t42   []*point // one per component of the rows
+	tHat       []*point
+
+	s1, s2, s3   *scalar
+	s4           []*scalar // one per component of the rows
+	sHat, sPrime []*scalar
+}
+
+// shuffleGenerators returns the independent generators h and h_1, ..., h_n of the commitments of a shuffle proof,
+// obtained by hashing to the group so that nobody knows their discrete logarithms.
+func shuffleGenerators(sid []byte, n int) (*point, []*point) {
+	gen := func(i int) *point {
+		return hashToPoint(binary.BigEndian.AppendUint32([]byte(shuffleGeneratorsDST), uint32(i)), sid)
+	}
+	hs := make([]*point, n)
+	for i := range hs {
+		hs[i] = gen(i + 1)
+	}
+	return gen(0), hs
+}
+
+// shuffleSeed returns the digest of the statement of a shuffle proof and of the permutation commitment, from
+// which the challenges are derived.
+func shuffleSeed(sid []byte, keys []*publicKey, inputs, outputs [][]*Ciphertext, coms []*point) []byte {
+	h := sha256.New()
+	h.Write([]byte(shuffleChallengeDST))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(sid))))
+	h.Write(sid)
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(keys))))
+	points := make([]*point, 0, len(keys))
+	for _, key := range keys {
+		points = append(points, &key.point)
+	}
+	for _, row := range slices.Concat(inputs, outputs) {
+		for _, ct := range row {
+			points = append(points, &ct.c0, &ct.c1)
+		}
+	}
+	for _, p := range append(points, coms...) {
+		b, _ := p.MarshalBinary()
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// shuffleChallenges derives the n challenges u_1, ..., u_n of a shuffle proof from the seed.
+func shuffleChallenges(seed []byte, n int) []*scalar {
+	u := make([]*scalar, n)
+	for i := range u {
+		u[i] = &scalar{s: group.HashToScalar(binary.BigEndian.AppendUint32(slices.Clone(seed), uint32(i)), []byte(shuffleChallengeDST))}
+	}
+	return u
+}
+
+// shuffleChallenge computes the Fiat-Shamir challenge of a shuffle proof.
+func shuffleChallenge(seed []byte, proof *ShuffleProof) *scalar {
+	transcript := slices.Clone(seed)
+	for _, p := range slices.Concat(proof.chain, []*point{proof.t1, proof.t2, proof.t3}, proof.t41, proof.t42, proof.tHat) {
+		b, _ := p.MarshalBinary()
+		transcript = append(transcript, b...)
+	}
+	return &scalar{s: group.HashToScalar(transcript, []byte(shuffleChallengeDST))}
+}
+
+// shuffleCiphertexts permutes the rows of ciphertexts with a secret random permutation, re-randomizes the
+// component k of the rows under keys[k], and proves it. The output at position i is a re-randomization of the
+// input at position psi[i]. The components for which rerand(j, k) is false for the input row j, such as the
+// public components of the rows, are carried as they are, and rerand may be nil to re-randomize all of them.
+func shuffleCiphertexts(sid []byte, keys []*publicKey, inputs [][]*Ciphertext, rerand func(j, k int) bool) (outputs [][]*Ciphertext, psi []int, proof *ShuffleProof) {
+	psi = secureRand().Perm(len(inputs))
+	zero := newScalar(big.NewInt(0))
+	outputs = make([][]*Ciphertext, len(inputs))
+	rPrime := make([][]*scalar, len(inputs))
+	for i, j := range psi {
+		outputs[i], rPrime[i] = make([]*Ciphertext, len(keys)), make([]*scalar, len(keys))
+		for k, key := range keys {
+			if rerand != nil && !rerand(j, k) {
+				outputs[i][k], rPrime[i][k] = inputs[j][k], zero
+				continue
+			}
+			rPrime[i][k] = randomScalar()
+			ct := reRandWithRandomness(key, inputs[j][k], rPrime[i][k])
+			outputs[i][k] = &ct
+		}
+	}
+	return outputs, psi, proveShuffle(sid, keys, inputs, outputs, psi, rPrime)
+}
+
+// proveShuffle computes the proof that the component k of outputs[i] is the re-randomization of the component k
+// of inputs[psi[i]] under keys[k] with rPrime[i][k].
+func proveShuffle(sid []byte, keys []*publicKey, inputs, outputs [][]*Ciphertext, psi []int, rPrime [][]*scalar) *ShuffleProof {
+	n, w := len(inputs), len(keys)
+	h, hs := shuffleGenerators(sid, n)
+	zero := newScalar(big.NewInt(0))
+
+	proof := &ShuffleProof{coms: make([]*point, n), chain: make([]*point, n), tHat: make([]*point, n), sHat: make([]*scalar, n), sPrime: make([]*scalar, n)}
+	r := make([]*scalar, n)
+	for i, j := range psi {
+		r[j] = randomScalar()
+		proof.coms[j] = mul(baseExp(r[j]), hs[i])
+	}
+
+	seed := shuffleSeed(sid, keys, inputs, outputs, proof.coms)
+	u := shuffleChallenges(seed, n)
+	uPrime := make([]*scalar, n)
+	for i, j := range psi {
+		uPrime[i] = u[j]
+	}
+
+	rHats := make([]*scalar, n)
+	prev := h
+	for i := range n {
+		rHats[i] = randomScalar()
+		proof.chain[i] = mul(baseExp(rHats[i]), prev.scalarExp(uPrime[i]))
+		prev = proof.chain[i]
+	}
+
+	rBar, rHatSum, rTilde := zero, zero, zero
+	rPrimeSums := make([]*scalar, w)
+	for k := range rPrimeSums {
+		rPrimeSums[k] = zero
+	}
+	v := newScalar(big.NewInt(1))
+	for i := n - 1; i >= 0; i-- {
+		rHatSum = rHatSum.add(rHats[i].mul(v))
+		v = v.mul(uPrime[i])
+	}
+	for i := range n {
+		rBar = rBar.add(r[i])
+		rTilde = rTilde.add(r[i].mul(u[i]))
+		for k := range rPrimeSums {
+			rPrimeSums[k] = rPrimeSums[k].add(rPrime[i][k].mul(uPrime[i]))
+		}
+	}
+
+	w1, w2, w3 := randomScalar(), randomScalar(), randomScalar()
+	w4 := make([]*scalar, w)
+	wHat, wPrime := make([]*scalar, n), make([]*scalar, n)
+	proof.t1, proof.t2, proof.t3 = baseExp(w1), baseExp(w2), baseExp(w3)
+	proof.t41, proof.t42 = make([]*point, w), make([]*point, w)
+	for k, key := range keys {
+		w4[k] = randomScalar()
+		proof.t41[k], proof.t42[k] = key.exp(w4[k].neg()), baseExp(w4[k].neg())
+	}
+	prev = h
+	for i := range n {
+		wHat[i], wPrime[i] = randomScalar(), randomScalar()
+		proof.t3 = mul(proof.t3, hs[i].scalarExp(wPrime[i]))
+		for k, ct := range outputs[i] {
+			proof.t41[k] = mul(proof.t41[k], ct.c1.scalarExp(wPrime[i]))
+			proof.t42[k] = mul(proof.t42[k], ct.c0.scalarExp(wPrime[i]))
+		}
+		proof.tHat[i] = mul(baseExp(wHat[i]), prev.scalarExp(wPrime[i]))
+		prev = proof.chain[i]
+	}
+
+	c := shuffleChallenge(seed, proof)
+	proof.s1, proof.s2, proof.s3 = w1.add(c.mul(rBar)), w2.add(c.mul(rHatSum)), w3.add(c.mul(rTilde))
+	proof.s4 = make([]*scalar, w)
+	for k := range w4 {
+		proof.s4[k] = w4[k].add(c.mul(rPrimeSums[k]))
+	}
+	for i := range n {
+		proof.sHat[i] = wHat[i].add(c.mul(rHats[i]))
+		proof.sPrime[i] = wPrime[i].add(c.mul(uPrime[i]))
+	}
+	return proof
+}
+
+// verifyShuffle checks the proof that outputs is a permutation of re-randomizations of inputs, whose
+// components k are re-randomized under keys[k]. The products of powers of the equations are computed with
+// multi-exponentiations, as all their terms are public.
+func verifyShuffle(sid []byte, keys []*publicKey, inputs, outputs [][]*Ciphertext, proof *ShuffleProof) bool {
+	n, w := len(inputs), len(keys)
+	if len(outputs) != n {
+		return false
+	}
+	for i := range n {
+		if len(inputs[i]) != w || len(outputs[i]) != w {
+			return false
+		}
+	}
+	if n == 0 {
+		return true
+	}
+	if proof == nil || len(proof.coms) != n || len(proof.chain) != n || len(proof.tHat) != n || len(proof.sHat) != n || len(proof.sPrime) != n ||
+		len(proof.t41) != w || len(proof.t42) != w || len(proof.s4) != w {
+		return false
+	}
+	h, hs := shuffleGenerators(sid, n)
+	seed := shuffleSeed(sid, keys, inputs, outputs, proof.coms)
+	u := shuffleChallenges(seed, n)
+	c := shuffleChallenge(seed, proof)
+	negC := c.neg()
+
+	// the exponents u_i * -c of the inputs, and the product of the u_i
+	negCU, uProd := make([]*scalar, n), newScalar(big.NewInt(1))
+	for i := range n {
+		negCU[i], uProd = u[i].mul(negC), uProd.mul(u[i])
+	}
+
+	prev := h
+	for i := range n {
+		tHat := multiExp([]*point{proof.chain[i], gen(), prev}, []*scalar{negC, proof.sHat[i], proof.sPrime[i]})
+		if !tHat.Equals(proof.tHat[i]) {
+			return false
+		}
+		prev = proof.chain[i]
+	}
+
+	cBar := mul(mulBatched(proof.coms), mulBatched(hs).invert())
+	cHat := mul(proof.chain[n-1], h.scalarExp(uProd).invert())
+	t3 := multiExp(slices.Concat(proof.coms, hs, []*point{gen()}), slices.Concat(negCU, proof.sPrime, []*scalar{proof.s3}))
+	if !proof.t1.Equals(mul(cBar.scalarExp(negC), baseExp(proof.s1))) ||
+		!proof.t2.Equals(mul(cHat.scalarExp(negC), baseExp(proof.s2))) ||
+		!proof.t3.Equals(t3) {
+		return false
+	}
+
+	bases0, bases1 := make([]*point, 2*n+1), make([]*point, 2*n+1)
+	exps := slices.Concat(negCU, proof.sPrime, []*scalar{nil})
+	for k, key := range keys {
+		for i := range n {
+			bases0[i], bases1[i] = &inputs[i][k].c0, &inputs[i][k].c1
+			bases0[n+i], bases1[n+i] = &outputs[i][k].c0, &outputs[i][k].c1
+		}
+		bases0[2*n], bases1[2*n], exps[2*n] = gen(), &key.point, proof.s4[k].neg()
+		if !proof.t41[k].Equals(multiExp(bases1, exps)) || !proof.t42[k].Equals(multiExp(bases0, exps)) {
+			return false
+		}
+	}
+	return true
+}
+
+// shuffleShape is the layout of the rows of ciphertexts shuffled by the helper: the UID ciphertext, followed by
+// the value ciphertexts padded to the largest number of columns of a source, and by the origin of the row. The
+// padding and the origins are trivial encryptions, with the identity as first element, which the shuffle
+// carries as they are, so that the auditor reconstructs them from the origins of the input rows and from the
+// payloads of the output rows. The rows of cardinality-only sessions have no values, and only carry their
+// origins if these are revealed to the receiver.
+type shuffleShape struct {
+	values int           // the number of value components
+	pad    *Ciphertext   // the padding of the values
+	origin []*Ciphertext // the origin components by source index, or nil if the rows carry no origin
+}
+
+func newShuffleShape(numColumns []int, cardinality bool, threshold int) *shuffleShape {
+	s := &shuffleShape{values: slices.Max(numColumns), pad: &Ciphertext{c0: *identity(), c1: *identity()}}
+	if !cardinality || threshold > 0 {
+		s.origin = make([]*Ciphertext, len(numColumns))
+		for t := range s.origin {
+			s.origin[t] = &Ciphertext{c0: *identity(), c1: *baseExp(newScalar(big.NewInt(int64(t))))}
+		}
+	}
+	return s
+}
+
+// width returns the number of components of the rows.
+func (s *shuffleShape) width() int {
+	if s.origin != nil {
+		return s.values + 2
+	}
+	return s.values + 1
+}
+
+// keys returns the keys under which the components of the rows are re-randomized.
+func (s *shuffleShape) keys(rpk PublicKey) []*publicKey {
+	keys := append(make([]*publicKey, 0, s.width()), rpk.bpk)
+	for range s.values {
+		keys = append(keys, rpk.epk)
+	}
+	if s.origin != nil {
+		keys = append(keys, rpk.bpk)
+	}
+	return keys
+}
+
+// row returns the row of the UID ciphertext cuid, of the value ciphertexts values and of the origin tindex.
+func (s *shuffleShape) row(cuid *Ciphertext, values []*Ciphertext, tindex int) []*Ciphertext {
+	row := append(append(make([]*Ciphertext, 0, s.width()), cuid), values...)
+	for len(row) < 1+s.values {
+		row = append(row, s.pad)
+	}
+	if s.origin != nil {
+		row = append(row, s.origin[tindex])
+	}
+	return row
+}
+
+// rerand returns whether the shuffle re-randomizes the component k of a row with n values.
+func (s *shuffleShape) rerand(k, n int) bool {
+	return k <= n
+}
+
+// conversionProof is the proof of the conversion of an output row of the helper, from the row of shuffled
+// ciphertexts at its position.
+type conversionProof struct {
+	eval    *HintProof // the pseudonym is the shuffled UID ciphertext raised to the conversion key
+	rp      *point     // the point from which the payload key is derived, nil in cardinality-only sessions
+	payload *HintProof // the value key divided by rp is the pseudonym raised to the pad key
+}
+
+// ShuffleTranscript is the evidence that the rows output by the helper are a permutation of the conversions of
+// its input rows, without dropped, duplicated or altered rows. The helper first shuffles the rows of ciphertexts
+// of its input, each made of the row's UID ciphertext, value ciphertexts and origin, with a proof of shuffle.
+// It then proves, for each output row, that its pseudonym is the shuffled UID ciphertext at the same position
+// raised to the conversion key, and that its payload holds the shuffled value ciphertexts and origin at the same
+// position: the transcript reveals the point from which the payload key of the row is derived, with which the
+// auditor decrypts the payload, along with a proof that the value key is this point blinded with the pseudonym
+// raised to the pad key, against the commitments of [Helper.Commitments].
+//
+// The hints of the rows are not covered by the transcript, and are proven to the receiver in sessions with
+// helper proofs, see [WithHelperProofs]. The symmetric ciphertexts of the large values are carried unchanged
+// from the input rows to the payloads, so that the auditor can link the rows with such values across the
+// shuffle, and learns the origins of the output rows.
+//
+// The transcript must not be disclosed to the receiver, who could decrypt the shuffled ciphertexts into the
+// hashes of the UIDs, before the pseudonymization by the helper.
+type ShuffleTranscript struct {
+	origins     []int           // the source indices of the input rows
+	inputs      [][]*Ciphertext // the UID ciphertext of each input row, followed by its value ciphertexts
+	shuffled    []*Ciphertext   // the shuffled UID ciphertexts
+	proof       *ShuffleProof
+	conversions []conversionProof
+}
+
+// Inputs returns the UID ciphertexts of the input rows, in the order in which the helper received them, for
+// comparison with the rows uploaded by the sources.
+func (t *ShuffleTranscript) Inputs() []*Ciphertext {
+	cuids := make([]*Ciphertext, len(t.inputs))
+	for i, row := range t.inputs {
+		cuids[i] = row[0]
+	}
+	return cuids
+}
+
+// VerifyShuffle checks that the rows output by the helper are in one-to-one correspondence with the input rows
+// of the transcript, through their pseudonyms, values and origins, given the helper's key commitments. It
+// returns an error wrapping [ErrInvalidProof] otherwise. It does not check the hints of the rows, see
+// [ShuffleTranscript].
+func VerifyShuffle(sess *Session, com KeyCommitments, transcript *ShuffleTranscript, output EncTableWithHint) error {
+	n := len(transcript.inputs)
+	if len(output) != n || len(transcript.origins) != n || len(transcript.shuffled) != n || len(transcript.conversions) != n {
+		return fmt.Errorf("%w: expected %d output rows, got %d", ErrInvalidProof, n, len(output))
+	}
+	if n == 0 {
+		return nil
+	}
+	if com.conv == nil || com.pad == nil {
+		return fmt.Errorf("%w: missing key commitments", ErrInvalidProof)
+	}
+
+	numColumns, anchor := make([]int, len(sess.Sources)), hiddenOrigin
+	for i, source := range sess.Sources {
+		numColumns[i] = sess.NumColumns(source)
+		if source == sess.Anchor {
+			anchor = i
+		}
+	}
+	shape := newShuffleShape(numColumns, sess.CardinalityOnly, sess.Threshold)
+
+	inputs := make([][]*Ciphertext, n)
+	for i, row := range transcript.inputs {
+		tindex := transcript.origins[i]
+		if tindex < 0 || tindex >= len(numColumns) || len(row) != 1+numColumns[tindex] {
+			return fmt.Errorf("%w: input row %d", ErrInvalidProof, i)
+		}
+		inputs[i] = shape.row(row[0], row[1:], tindex)
+	}
+
+	outputs := make([][]*Ciphertext, n)
+	statements, rows := make([]statement, 0, 2*n), make([]int, 0, 2*n) // the statements and their output rows
+	var k kdf
+	for i := range output {
+		row, conv := &output[i], &transcript.conversions[i]
+		statements = append(statements, hintStatement{keyComs: []*point{com.conv}, cnyme: transcript.shuffled[i], hint: &row.Cnyme, proof: conv.eval})
+		rows = append(rows, i)
+
+		if sess.CardinalityOnly {
+			if shape.origin != nil && (row.Origin < 0 || row.Origin >= len(numColumns)) {
+				return fmt.Errorf("%w: origin of output row %d", ErrInvalidProof, i)
+			}
+			outputs[i] = shape.row(transcript.shuffled[i], nil, row.Origin)
+			continue
+		}
+
+		tindex, values, err := openPayload(&k, sess.ID, row, conv.rp, len(numColumns))
+		if err != nil {
+			return fmt.Errorf("%w: payload of output row %d: %v", ErrInvalidProof, i, err)
+		}
+		origin := hiddenOrigin
+		if sess.Threshold > 0 || tindex == anchor {
+			origin = tindex
+		}
+		if row.Origin != origin || len(values) != numColumns[tindex] {
+			return fmt.Errorf("%w: payload of output row %d", ErrInvalidProof, i)
+		}
+		cvals := make([]*Ciphertext, len(values))
+		for j := range values {
+			cvals[j] = &values[j].CKey
+		}
+		outputs[i] = shape.row(transcript.shuffled[i], cvals, tindex)
+
+		keyCom := com.pad
+		if tindex == anchor {
+			keyCom = identity() // the value keys of the anchor rows are not blinded
+		}
+		unblinded := &Ciphertext{c0: row.CValKey.c0, c1: *mul(&row.CValKey.c1, conv.rp.invert())}
+		statements = append(statements, hintStatement{keyComs: []*point{keyCom}, cnyme: &row.Cnyme, hint: unblinded, proof: conv.payload})
+		rows = append(rows, i)
+	}
+
+	if !verifyShuffle(sess.ID, shape.keys(sess.ReceiverPK), inputs, outputs, transcript.proof) {
+		return fmt.Errorf("%w: shuffle", ErrInvalidProof)
+	}
+	if i := verifyProofs(sess.ID, sess.ReceiverPK.bpk, statements); i != -1 {
+		return fmt.Errorf("%w: conversion of output row %d", ErrInvalidProof, rows[i])
+	}
+	return nil
+}
+
+// openPayload decrypts the payload of a converted row with the key derived from rp, and returns the index of the
+// source of the row along with its values.
+func openPayload(k *kdf, sid []byte, row *EncRowWithHint, rp *point, numSources int) (int, []EncValue, error) {
+	if rp == nil {
+		return 0, nil, errors.New("missing payload key")
+	}
+	key, err := k.key(rp, sid, helperKeyInfo)
+	if err != nil {
+		return 0, nil, err
+	}
+	plaintext, err := symmetricDecrypt(key, row.CVal, appendPayloadAD(nil, sid, row.Origin))
+	k.clear()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(plaintext) < originSize {
+		return 0, nil, errors.New("payload too short")
+	}
+	tindex := int(binary.BigEndian.Uint16(plaintext))
+	if tindex >= numSources {
+		return 0, nil, fmt.Errorf("invalid source index: %d", tindex)
+	}
+	values, err := deserializeEncValues(plaintext[originSize:])
+	if err != nil {
+		return 0, nil, err
+	}
+	return tindex, values, nil
+}
+
+// MarshalBinary serializes a ShuffleTranscript into a byte slice: the number of rows and the number of
+// components of the shuffled rows, the input rows with their origins and their numbers of ciphertexts, the
+// shuffled UID ciphertexts, the conversion proofs, of which the point rp and the payload proof are preceded by
+// a presence byte, and the proof of shuffle.
+func (t *ShuffleTranscript) MarshalBinary() ([]byte, error) {
+	n := len(t.inputs)
+	if len(t.origins) != n || len(t.shuffled) != n || len(t.conversions) != n || (n > 0 && t.proof == nil) {
+		return nil, fmt.Errorf("inconsistent transcript")
+	}
+	w := 0
+	if n > 0 {
+		w = len(t.proof.t41)
+	}
+	buf := binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint32(nil, uint32(n)), uint16(w))
+	appendCiphertexts := func(buf []byte, cts []*Ciphertext) ([]byte, error) {
+		for _, ct := range cts {
+			b, err := ct.Serialize()
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+		return buf, nil
+	}
+
+	var err error
+	for i, row := range t.inputs {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(t.origins[i]))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(row)))
+		if buf, err = appendCiphertexts(buf, row); err != nil {
+			return nil, err
+		}
+	}
+	if buf, err = appendCiphertexts(buf, t.shuffled); err != nil {
+		return nil, err
+	}
+	for _, conv := range t.conversions {
+		if conv.eval == nil {
+			return nil, fmt.Errorf("inconsistent transcript")
+		}
+		b, err := conv.eval.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+		if conv.rp == nil || conv.payload == nil {
+			buf = append(buf, 0)
+			continue
+		}
+		if buf, err = appendPoints(append(buf, 1), conv.rp); err != nil {
+			return nil, err
+		}
+		if b, err = conv.payload.MarshalBinary(); err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	if n == 0 {
+		return buf, nil
+	}
+	p := t.proof
+	if buf, err = appendPoints(buf, slices.Concat(p.coms, p.chain, []*point{p.t1, p.t2, p.t3}, p.t41, p.t42, p.tHat)...); err != nil {
+		return nil, err
+	}
+	return appendScalars(buf, slices.Concat([]*scalar{p.s1, p.s2, p.s3}, p.s4, p.sHat, p.sPrime)...)
+}
+
+// UnmarshalBinary deserializes a byte slice into a ShuffleTranscript.
+func (t *ShuffleTranscript) UnmarshalBinary(data []byte) error {
+	errLength := fmt.Errorf("invalid byte slice length for deserialization of transcript")
+	pointLen, scalarLen := int(group.Params().CompressedElementLength), int(group.Params().ScalarLength)
+	if len(data) < 6 {
+		return errLength
+	}
+	n, w := int(binary.BigEndian.Uint32(data)), int(binary.BigEndian.Uint16(data[4:]))
+	if n > len(data) {
+		return errLength
+	}
+	data = data[6:]
+	readCiphertexts := func(m int) ([]*Ciphertext, error) {
+		if len(data) < m*ciphertextSize {
+			return nil, errLength
+		}
+		cts := make([]*Ciphertext, m)
+		for i := range cts {
+			ct, err := DeserializeCiphertext(data[:ciphertextSize])
+			if err != nil {
+				return nil, err
+			}
+			cts[i], data = ct, data[ciphertextSize:]
+		}
+		return cts, nil
+	}
+	readHintProof := func() (*HintProof, error) {
+		size := hintProofLen(data)
+		if size < 0 {
+			return nil, errLength
+		}
+		proof := new(HintProof)
+		if err := proof.UnmarshalBinary(data[:size]); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+		return proof, nil
+	}
+
+	*t = ShuffleTranscript{origins: make([]int, n), inputs: make([][]*Ciphertext, n), conversions: make([]conversionProof, n)}
+	var err error
+	for i := range t.inputs {
+		if len(data) < 4 {
+			return errLength
+		}
+		t.origins[i] = int(binary.BigEndian.Uint16(data))
+		m := int(binary.BigEndian.Uint16(data[2:]))
+		data = data[4:]
+		if m == 0 {
+			return fmt.Errorf("input row without UID ciphertext")
+		}
+		if t.inputs[i], err = readCiphertexts(m); err != nil {
+			return err
+		}
+	}
+	if t.shuffled, err = readCiphertexts(n); err != nil {
+		return err
+	}
+	for i := range t.conversions {
+		conv := &t.conversions[i]
+		if conv.eval, err = readHintProof(); err != nil {
+			return err
+		}
+		if len(data) < 1 {
+			return errLength
+		}
+		present := data[0]
+		data = data[1:]
+		if present == 0 {
+			continue
+		}
+		var rp []*point
+		if rp, data, err = readPoints(data, 1); err != nil {
+			return err
+		}
+		conv.rp = rp[0]
+		if conv.payload, err = readHintProof(); err != nil {
+			return err
+		}
+	}
+
+	if n == 0 {
+		if len(data) != 0 {
+			return errLength
+		}
+		return nil
+	}
+	numPoints, numScalars := 3*n+3+2*w, 2*n+3+w
+	if len(data) != numPoints*pointLen+numScalars*scalarLen {
+		return errLength
+	}
+	pts, data, err := readPoints(data, numPoints)
+	if err != nil {
+		return err
+	}
+	scs, err := readScalars(data, numScalars)
+	if err != nil {
+		return err
+	}
+	t.proof = &ShuffleProof{
+		coms: pts[:n], chain: pts[n : 2*n],
+		t1: pts[2*n], t2: pts[2*n+1], t3: pts[2*n+2],
+		t41: pts[2*n+3 : 2*n+3+w], t42: pts[2*n+3+w : 2*n+3+2*w],
+		tHat: pts[2*n+3+2*w:],
+		s1:   scs[0], s2: scs[1], s3: scs[2],
+		s4:   scs[3 : 3+w],
+		sHat: scs[3+w : n+3+w], sPrime: scs[n+3+w:],
+	}
+	return nil
+}
+
+// appendPoints appends the compressed serializations of points to buf.
+func appendPoints(buf []byte, points ...*point) ([]byte, error) {
+	for _, p := range points {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != int(group.Params().CompressedElementLength) {
+			return nil, fmt.Errorf("invalid point")
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// appendScalars appends the serializations of scalars to buf.
+func appendScalars(buf []byte, scalars ...*scalar) ([]byte, error) {
+	for _, s := range scalars {
+		b, err := s.s.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// readPoints reads n compressed points from data, and returns them along with the rest of data.
+func readPoints(data []byte, n int) ([]*point, []byte, error) {
+	pointLen := int(group.Params().CompressedElementLength)
+	if len(data) < n*pointLen {
+		return nil, nil, fmt.Errorf("invalid byte slice length for deserialization of points")
+	}
+	points := make([]*point, n)
+	for i := range points {
+		points[i] = newPoint()
+		if err := points[i].UnmarshalBinary(data[:pointLen]); err != nil {
+			return nil, nil, err
+		}
+		data = data[pointLen:]
+	}
+	return points, data, nil
+}
+
+// readScalars reads n scalars from data, which must hold exactly n scalars.
+func readScalars(data []byte, n int) ([]*scalar, error) {
+	scalarLen := int(group.Params().ScalarLength)
+	scalars := make([]*scalar, n)
+	for i := range scalars {
+		scalars[i] = &scalar{s: group.NewScalar()}
+		if err := scalars[i].s.UnmarshalBinary(data[i*scalarLen : (i+1)*scalarLen]); err != nil {
+			return nil, err
+		}
+	}
+	return scalars, nil
+}
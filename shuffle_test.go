@@ -0,0 +1,223 @@
+package mppj
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShuffleProof(t *testing.T) {
+	_, rpk := KeyGen()
+	sid := []byte("session")
+	keys := []*publicKey{rpk.bpk, rpk.epk, rpk.bpk}
+	rerand := func(j, k int) bool { return k < 2 } // the last component is public
+
+	for _, n := range []int{0, 1, 2, 7} {
+		inputs := make([][]*Ciphertext, n)
+		for i := range inputs {
+			inputs[i] = make([]*Ciphertext, len(keys))
+			for k, key := range keys {
+				ct := encryptPKE(key, &message{m: *randomPoint()})
+				inputs[i][k] = &ct
+			}
+		}
+		outputs, psi, proof := shuffleCiphertexts(sid, keys, inputs, rerand)
+		require.True(t, verifyShuffle(sid, keys, inputs, outputs, proof), "n=%d", n)
+		require.Len(t, psi, n)
+		for i, j := range psi {
+			require.Same(t, inputs[j][2], outputs[i][2], "public component re-randomized")
+		}
+		if n < 2 {
+			continue
+		}
+		require.False(t, verifyShuffle([]byte("other session"), keys, inputs, outputs, proof), "n=%d", n)
+		require.False(t, verifyShuffle(sid, []*publicKey{rpk.bpk, rpk.bpk, rpk.bpk}, inputs, outputs, proof), "n=%d", n)
+
+		// a dropped row replaced by a duplicate of another row
+		duplicated := slices.Clone(outputs)
+		duplicated[0] = make([]*Ciphertext, len(keys))
+		for k, key := range keys {
+			rerand := reRand(key, outputs[1][k])
+			duplicated[0][k] = &rerand
+		}
+		require.False(t, verifyShuffle(sid, keys, inputs, duplicated, proof), "n=%d", n)
+
+		// the components of two rows swapped, so that the rows mix the components of two input rows
+		swapped := slices.Clone(outputs)
+		swapped[0], swapped[1] = slices.Clone(outputs[0]), slices.Clone(outputs[1])
+		swapped[0][1], swapped[1][1] = outputs[1][1], outputs[0][1]
+		require.False(t, verifyShuffle(sid, keys, inputs, swapped, proof), "n=%d", n)
+
+		// a row that is not a re-randomization of its input
+		_, _, other := shuffleCiphertexts(sid, keys, inputs, rerand)
+		require.False(t, verifyShuffle(sid, keys, inputs, outputs, other), "n=%d", n)
+	}
+}
+
+func TestMPPJShuffleProof(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	require.NoError(t, err, "NewSession() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+	}
+
+	joinedTables, transcript, err := helper.ConvertWithShuffleProof(encTables)
+	require.NoError(t, err, "ConvertWithShuffleProof() error")
+
+	// the transcript goes through the wire format
+	data, err := transcript.MarshalBinary()
+	require.NoError(t, err)
+	var audited ShuffleTranscript
+	require.NoError(t, audited.UnmarshalBinary(data))
+	require.Len(t, audited.Inputs(), 9)
+	comBytes, err := helper.Commitments().MarshalBinary()
+	require.NoError(t, err)
+	var com KeyCommitments
+	require.NoError(t, com.UnmarshalBinary(comBytes))
+	require.NoError(t, VerifyShuffle(sess, com, &audited, joinedTables))
+
+	// the commitments of another helper
+	require.ErrorIs(t, VerifyShuffle(sess, NewHelper(sess).Commitments(), &audited, joinedTables), ErrInvalidProof)
+
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+
+	// a dropped row
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, joinedTables[1:]), ErrInvalidProof)
+
+	// a row dropped and replaced with a duplicate of another row
+	duplicated := append(EncTableWithHint{}, joinedTables...)
+	duplicated[0] = duplicated[1]
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, duplicated), ErrInvalidProof)
+
+	// a pseudonym computed with another key
+	tampered := append(EncTableWithHint{}, joinedTables...)
+	tampered[2].Cnyme = oprfEval(oprfKeyGen(), rpk.bpk, &tampered[2].Cnyme)
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, tampered), ErrInvalidProof)
+
+	// the payloads of two rows swapped
+	tampered = append(EncTableWithHint{}, joinedTables...)
+	tampered[0].CVal, tampered[1].CVal = tampered[1].CVal, tampered[0].CVal
+	tampered[0].CValKey, tampered[1].CValKey = tampered[1].CValKey, tampered[0].CValKey
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, tampered), ErrInvalidProof)
+
+	// a value replaced in the payload, under the same payload key
+	tampered = append(EncTableWithHint{}, joinedTables...)
+	rp := audited.conversions[3].rp
+	tindex, values, err := openPayload(new(kdf), sess.ID, &tampered[3], rp, len(sourceIDs))
+	require.NoError(t, err)
+	values[0].CKey = encryptPKE(rpk.epk, &message{m: *randomPoint()})
+	plaintext, err := appendEncValues(binary.BigEndian.AppendUint16(nil, uint16(tindex)), values)
+	require.NoError(t, err)
+	key, err := keyFromPoint(rp, sess.ID, helperKeyInfo)
+	require.NoError(t, err)
+	tampered[3].CVal, err = symmetricEncrypt(key, plaintext, appendPayloadAD(nil, sess.ID, tampered[3].Origin))
+	require.NoError(t, err)
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, tampered), ErrInvalidProof)
+
+	// a value key blinding another point
+	tampered = append(EncTableWithHint{}, joinedTables...)
+	tampered[4].CValKey.c1 = *mul(&tampered[4].CValKey.c1, randomPoint())
+	require.ErrorIs(t, VerifyShuffle(sess, com, &audited, tampered), ErrInvalidProof)
+}
+
+func TestShuffleProofSessions(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	columns := map[PartyID][]string{
+		"ds1": {"name", "email"},
+		"ds2": {"country", "city", "zip"},
+		"ds3": {"value"},
+	}
+	tables := make(map[PartyID]MultiTablePlain, len(sourceIDs))
+	for sourceID, table := range GenTestTables(sourceIDs, 20, 5) {
+		multi := MultiTablePlain{Columns: columns[sourceID]}
+		for uid, val := range table {
+			vals := make([]string, len(multi.Columns))
+			for i, col := range multi.Columns {
+				vals[i] = col + "_" + val
+			}
+			multi.Rows = append(multi.Rows, Row{UID: uid, Values: vals})
+		}
+		tables[sourceID] = multi
+	}
+
+	for _, test := range []struct {
+		name        string
+		cardinality bool
+		opts        []SessionOption
+	}{
+		{"columns", false, nil},
+		{"threshold", false, []SessionOption{WithThreshold(2)}},
+		{"anchor", false, []SessionOption{WithAnchor("ds1")}},
+		{"cardinality", true, []SessionOption{WithCardinalityOnly()}},
+		{"cardinality threshold", true, []SessionOption{WithCardinalityOnly(), WithThreshold(2)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, rpk := KeyGen()
+			opts := test.opts
+			if !test.cardinality {
+				opts = append(opts, WithColumns("ds1", columns["ds1"]...), WithColumns("ds2", columns["ds2"]...), WithColumns("ds3", columns["ds3"]...))
+			}
+			sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+			require.NoError(t, err, "NewSession() error")
+
+			helper := NewHelper(sess)
+			ds := NewDataSource(sess)
+			encTables := make(map[PartyID]EncTable, len(tables))
+			for sourceID, table := range tables {
+				if test.cardinality {
+					plain := make(TablePlain, len(table.Rows))
+					for _, row := range table.Rows {
+						plain[row.UID] = row.Values[0]
+					}
+					encTables[sourceID], err = ds.Prepare(plain)
+				} else {
+					encTables[sourceID], err = ds.PrepareMulti(table)
+				}
+				require.NoError(t, err, "Prepare() error")
+			}
+
+			joinedTables, transcript, err := helper.ConvertWithShuffleProof(encTables)
+			require.NoError(t, err, "ConvertWithShuffleProof() error")
+			data, err := transcript.MarshalBinary()
+			require.NoError(t, err)
+			var audited ShuffleTranscript
+			require.NoError(t, audited.UnmarshalBinary(data))
+			require.NoError(t, VerifyShuffle(sess, helper.Commitments(), &audited, joinedTables))
+
+			// an input row attributed to another source, when the origins are revealed to the receiver
+			if !test.cardinality || sess.Threshold > 0 {
+				forged := audited
+				forged.origins = slices.Clone(audited.origins)
+				forged.origins[0] = (forged.origins[0] + 1) % len(sourceIDs)
+				require.ErrorIs(t, VerifyShuffle(sess, helper.Commitments(), &forged, joinedTables), ErrInvalidProof)
+			}
+
+			require.Error(t, audited.UnmarshalBinary(data[:len(data)-1]), "expected an error for a truncated transcript")
+		})
+	}
+}
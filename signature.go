@@ -0,0 +1,142 @@
+package mppj
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned by the helper when a chunk of rows does not carry a valid signature of its source,
+// and, in sessions with registered source keys, for the rows that do not come from a verified chunk.
+var ErrInvalidSignature = errors.New("invalid source signature")
+
+// ErrReplayedChunk is returned by the helper for a chunk of rows that it already accepted.
+var ErrReplayedChunk = errors.New("replayed chunk")
+
+const signatureDST = "mppj signed rows"
+
+// SignedRows is a chunk of encrypted rows uploaded by a source, along with the source's signature over the
+// session ID, the source ID and the digest of the rows. A source may upload its table in one or several chunks.
+type SignedRows struct {
+	Source    PartyID
+	Rows      EncTable
+	Signature []byte
+}
+
+// rowsDigest returns the digest signed by the source of a chunk of rows. The variable-length fields are
+// length-prefixed so that the digest is computed over an unambiguous encoding.
+func rowsDigest(sid []byte, source PartyID, rows EncTable) ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte(signatureDST))
+	for _, field := range [][]byte{sid, []byte(source)} {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		h.Write(field)
+	}
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(rows))))
+	for _, row := range rows {
+		data, err := row.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+		h.Write(data)
+	}
+	return h.Sum(nil), nil
+}
+
+// Sign signs a chunk of encrypted rows of the data source with its signing key, for upload to the helper.
+func (s *DataSource) Sign(rows EncTable) (SignedRows, error) {
+	if s.signingKey == nil || s.id == "" {
+		return SignedRows{}, fmt.Errorf("signing key and source ID required to sign rows")
+	}
+	digest, err := rowsDigest(s.sid, s.id, rows)
+	if err != nil {
+		return SignedRows{}, err
+	}
+	return SignedRows{Source: s.id, Rows: rows, Signature: ed25519.Sign(s.signingKey, digest)}, nil
+}
+
+// VerifyRows returns an error wrapping [ErrInvalidSignature] if the chunk is not signed by the registered key
+// of its source. As the source ID of a chunk is authenticated by the signature, it replaces the one claimed
+// in the gRPC metadata, see [SourceIDFromIncomingContext]. VerifyRows does not accept the rows for conversion,
+// see [Helper.AcceptRows].
+func (h *Helper) VerifyRows(chunk SignedRows) error {
+	_, err := h.verifyChunk(chunk)
+	return err
+}
+
+// verifyChunk verifies the signature of the chunk, and returns its digest.
+func (h *Helper) verifyChunk(chunk SignedRows) ([32]byte, error) {
+	pk, ok := h.sourceKeys[chunk.Source]
+	if !ok {
+		return [32]byte{}, fmt.Errorf("no signing key registered for source %s", chunk.Source)
+	}
+	digest, err := rowsDigest(h.sid, chunk.Source, chunk.Rows)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if !ed25519.Verify(pk, digest, chunk.Signature) {
+		return [32]byte{}, fmt.Errorf("%w: chunk of source %s", ErrInvalidSignature, chunk.Source)
+	}
+	return [32]byte(digest), nil
+}
+
+// AcceptRows verifies the signatures of the chunks, and returns their rows as conversion tasks for the
+// streaming conversion methods, which only convert the rows of accepted chunks in sessions with registered
+// source keys. The helper accepts each chunk once, and returns an error wrapping [ErrReplayedChunk] for the
+// chunks it already accepted, so that the rows of a chunk cannot be replayed into the join. If a chunk fails,
+// none of the chunks is accepted.
+func (h *Helper) AcceptRows(chunks ...SignedRows) ([]ConvertRowTask, error) {
+	digests := make(map[[32]byte]struct{}, len(chunks))
+	n := 0
+	for _, chunk := range chunks {
+		digest, err := h.verifyChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := digests[digest]; ok {
+			return nil, fmt.Errorf("%w: chunk of source %s", ErrReplayedChunk, chunk.Source)
+		}
+		digests[digest] = struct{}{}
+		n += len(chunk.Rows)
+	}
+
+	h.acceptedMu.Lock()
+	defer h.acceptedMu.Unlock()
+	for digest := range digests {
+		if _, ok := h.accepted[digest]; ok {
+			return nil, fmt.Errorf("%w: chunk with digest %x", ErrReplayedChunk, digest[:8])
+		}
+	}
+	if h.accepted == nil {
+		h.accepted = make(map[[32]byte]struct{})
+	}
+	for digest := range digests {
+		h.accepted[digest] = struct{}{}
+	}
+
+	tasks := make([]ConvertRowTask, 0, n)
+	for _, chunk := range chunks {
+		for _, row := range chunk.Rows {
+			tasks = append(tasks, ConvertRowTask{EncRowMsg: row, SourceID: chunk.Source, signer: chunk.Source})
+		}
+	}
+	return tasks, nil
+}
+
+// ConvertSigned is the version of [Helper.Convert] for sessions with registered source keys. It accepts all
+// the chunks with [Helper.AcceptRows] before converting their rows, and converts nothing if one of them fails.
+func (h *Helper) ConvertSigned(chunks []SignedRows) (EncTableWithHint, error) {
+	tasks, err := h.AcceptRows(chunks...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return h.ConvertStream(ctx, h.rpk, feedTasks(ctx, tasks))
+}
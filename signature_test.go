@@ -0,0 +1,163 @@
+package mppj
+
+import (
+	"context"
+	"crypto/ed25519"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPPJSignedUploads(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	rsk, rpk := KeyGen()
+	signingKeys := make(map[PartyID]ed25519.PrivateKey, len(sourceIDs))
+	opts := make([]SessionOption, 0, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		pk, sk, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		signingKeys[sourceID] = sk
+		opts = append(opts, WithSourceKey(sourceID, pk))
+	}
+
+	_, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts[:2]...)
+	require.Error(t, err, "expected an error with a missing source key")
+
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+	require.NoError(t, err, "NewSession() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	chunks := make([]SignedRows, 0)
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		ds := NewDataSource(sess, WithSourceID(sourceID), WithSigningKey(signingKeys[sourceID]))
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+		for _, rows := range []EncTable{prepTable[:1], prepTable[1:]} { // uploaded in two chunks
+			chunk, err := ds.Sign(rows)
+			require.NoError(t, err, "Sign() error")
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	_, err = helper.Convert(encTables)
+	require.Error(t, err, "expected an error for unsigned tables")
+
+	joinedTables, err := helper.ConvertSigned(chunks)
+	require.NoError(t, err, "ConvertSigned() error")
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+
+	// a chunk claimed by another source, or with altered rows, is rejected
+	chunk := chunks[0]
+	chunk.Source = sourceIDs[0]
+	if chunks[0].Source == sourceIDs[0] {
+		chunk.Source = sourceIDs[1]
+	}
+	require.ErrorIs(t, helper.VerifyRows(chunk), ErrInvalidSignature)
+
+	chunk = chunks[1]
+	chunk.Rows = append(EncTable{chunks[0].Rows[0]}, chunk.Rows[1:]...)
+	require.ErrorIs(t, helper.VerifyRows(chunk), ErrInvalidSignature)
+	_, err = helper.ConvertSigned(append(chunks[:1:1], chunk))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// a chunk signed for another session is rejected
+	other, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+	require.NoError(t, err)
+	chunk, err = NewDataSource(other, WithSourceID(chunks[0].Source), WithSigningKey(signingKeys[chunks[0].Source])).Sign(chunks[0].Rows)
+	require.NoError(t, err)
+	require.ErrorIs(t, helper.VerifyRows(chunk), ErrInvalidSignature)
+}
+
+func TestAcceptRows(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b"},
+		"ds2": {"a": "2a", "c": "2c"},
+	}
+
+	rsk, rpk := KeyGen()
+	signingKeys := make(map[PartyID]ed25519.PrivateKey, len(sourceIDs))
+	opts := make([]SessionOption, 0, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		pk, sk, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		signingKeys[sourceID] = sk
+		opts = append(opts, WithSourceKey(sourceID, pk))
+	}
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, opts...)
+	require.NoError(t, err, "NewSession() error")
+
+	helper := NewHelper(sess)
+	receiver := NewReceiver(sess, rsk)
+
+	chunks := make([]SignedRows, 0, len(sourceIDs))
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for _, sourceID := range sourceIDs {
+		ds := NewDataSource(sess, WithSourceID(sourceID), WithSigningKey(signingKeys[sourceID]))
+		prepTable, err := ds.Prepare(tables[sourceID])
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+		chunk, err := ds.Sign(prepTable)
+		require.NoError(t, err, "Sign() error")
+		chunks = append(chunks, chunk)
+	}
+
+	// the rows that were not accepted are rejected by all the conversion methods
+	ctx := context.Background()
+	_, err = helper.ConvertStream(ctx, rpk, helper.feedTables(ctx, encTables))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, _, err = helper.ConvertStreamWithShuffleProof(ctx, rpk, helper.feedTables(ctx, encTables))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	convRows, errc := helper.ConvertStreamExternal(ctx, rpk, helper.feedTables(ctx, encTables))
+	for range convRows {
+	}
+	require.ErrorIs(t, <-errc, ErrInvalidSignature)
+	_, err = helper.ConvertRow(rpk, &chunks[0].Rows[0], chunks[0].Source)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// a chunk is accepted only once, including within a call
+	_, err = helper.AcceptRows(chunks[0], chunks[0])
+	require.ErrorIs(t, err, ErrReplayedChunk)
+	tasks, err := helper.AcceptRows(chunks...)
+	require.NoError(t, err, "AcceptRows() error")
+	_, err = helper.AcceptRows(chunks[1])
+	require.ErrorIs(t, err, ErrReplayedChunk)
+	_, err = helper.ConvertSigned(chunks)
+	require.ErrorIs(t, err, ErrReplayedChunk)
+
+	// the source of an accepted row cannot be changed
+	forged := slices.Clone(tasks)
+	forged[0].SourceID = sourceIDs[1]
+	if tasks[0].SourceID == sourceIDs[1] {
+		forged[0].SourceID = sourceIDs[0]
+	}
+	_, err = helper.ConvertStream(ctx, rpk, feedTasks(ctx, forged))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	joinedTables, err := helper.ConvertStream(ctx, rpk, feedTasks(ctx, tasks))
+	require.NoError(t, err, "ConvertStream() error")
+	intersection, err := receiver.JoinTables(joinedTables)
+	require.NoError(t, err, "JoinTables() error")
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+}
@@ -0,0 +1,188 @@
+package mppj
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultSpillBuckets is the number of on-disk buckets of the out-of-core receiver and of the external
+// shuffle of the helper, if not specified.
+const defaultSpillBuckets = 256
+
+// spillBuckets is a set of files holding records, which a party partitions into buckets small enough to be
+// processed in memory one at a time. The files are created in a temporary directory, readable by the
+// current user only, which is removed by remove.
+type spillBuckets struct {
+	dir string
+
+	mu      []sync.Mutex
+	files   []*os.File
+	writers []*bufio.Writer
+}
+
+// newSpillBuckets creates n empty buckets in a new temporary directory inside parent, or inside the default
+// directory for temporary files if parent is empty.
+func newSpillBuckets(parent string, n int) (*spillBuckets, error) {
+	dir, err := os.MkdirTemp(parent, "mppj-")
+	if err != nil {
+		return nil, err
+	}
+	b := &spillBuckets{dir: dir, mu: make([]sync.Mutex, n), files: make([]*os.File, n), writers: make([]*bufio.Writer, n)}
+	for i := range n {
+		f, err := os.OpenFile(b.path(i), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			b.remove()
+			return nil, err
+		}
+		b.files[i], b.writers[i] = f, bufio.NewWriter(f)
+	}
+	return b, nil
+}
+
+func (b *spillBuckets) path(i int) string {
+	return filepath.Join(b.dir, fmt.Sprintf("bucket-%d", i))
+}
+
+// len returns the number of buckets.
+func (b *spillBuckets) len() int {
+	return len(b.files)
+}
+
+// write appends the record to bucket i, prefixed by its length on 4 bytes. It is safe for concurrent use.
+func (b *spillBuckets) write(i int, record []byte) error {
+	b.mu[i].Lock()
+	defer b.mu[i].Unlock()
+	if _, err := b.writers[i].Write(binary.BigEndian.AppendUint32(nil, uint32(len(record)))); err != nil {
+		return err
+	}
+	_, err := b.writers[i].Write(record)
+	return err
+}
+
+// flush writes the buffered records of all the buckets to their files.
+func (b *spillBuckets) flush() error {
+	for _, w := range b.writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read calls f on the records of bucket i, in their order of writing. The buckets must be flushed beforehand,
+// and the records are not retained after f returns.
+func (b *spillBuckets) read(i int, f func(record []byte) error) error {
+	file := b.files[i]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(file)
+	var record []byte
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading bucket %d: %w", i, err)
+		}
+		n := int(binary.BigEndian.Uint32(length[:]))
+		if cap(record) < n {
+			record = make([]byte, n)
+		}
+		record = record[:n]
+		if _, err := io.ReadFull(r, record); err != nil {
+			return fmt.Errorf("reading bucket %d: %w", i, err)
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discard removes the file of bucket i, which cannot be used afterwards.
+func (b *spillBuckets) discard(i int) error {
+	file := b.files[i]
+	b.files[i] = nil
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(b.path(i))
+}
+
+// remove closes the remaining files of the buckets, and removes their directory.
+func (b *spillBuckets) remove() error {
+	for _, f := range b.files {
+		if f != nil {
+			f.Close()
+		}
+	}
+	return os.RemoveAll(b.dir)
+}
+
+// nymBucket returns the bucket of the pseudonym nym among n buckets, from the bytes following its first
+// byte, see groupShard.
+func nymBucket(nym []byte, n int) int {
+	if len(nym) < 5 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(nym[1:]) % uint32(n))
+}
+
+// writeGrouped writes the row with pseudonym nym to the bucket of nym, as the length of the pseudonym on 2
+// bytes, the pseudonym, the position of the row on 8 bytes and the row.
+func (b *spillBuckets) writeGrouped(nym []byte, row EncRowWithHint) error {
+	rowBytes, err := row.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	record := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(nym)+8+len(rowBytes)), uint16(len(nym)))
+	record = append(record, nym...)
+	record = binary.BigEndian.AppendUint64(record, uint64(row.pos))
+	record = append(record, rowBytes...)
+	return b.write(nymBucket(nym, b.len()), record)
+}
+
+// feedGroups flushes the buckets written by writeGrouped, and calls yield on the groups of rows with the same
+// pseudonym, one bucket at a time. The file of each bucket is discarded once its groups are formed, so that
+// a single bucket is held in memory, along with the groups yield may retain.
+func (b *spillBuckets) feedGroups(yield func(group []EncRowWithHint) bool) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	for i := range b.len() {
+		groups := make(map[string][]EncRowWithHint)
+		err := b.read(i, func(record []byte) error {
+			if len(record) < 2 || len(record) < 2+int(binary.BigEndian.Uint16(record))+8 {
+				return fmt.Errorf("invalid record in bucket %d", i)
+			}
+			nymLen := 2 + int(binary.BigEndian.Uint16(record))
+			nym, record := record[2:nymLen], record[nymLen:]
+			var row EncRowWithHint
+			if err := row.UnmarshalBinary(record[8:]); err != nil {
+				return err
+			}
+			row.pos = int(binary.BigEndian.Uint64(record))
+			groups[string(nym)] = append(groups[string(nym)], row)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := b.discard(i); err != nil {
+			return err
+		}
+		for _, group := range groups {
+			if !yield(group) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
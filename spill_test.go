@@ -0,0 +1,267 @@
+package mppj
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPPJOutOfCore(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d"},
+	}
+
+	for _, test := range []struct {
+		name     string
+		opts     []SessionOption
+		expected JoinTable
+	}{
+		{"all", nil, IntersectPlain(tables, sourceIDs)},
+		{"threshold", []SessionOption{WithThreshold(2)}, IntersectPlainThreshold(tables, sourceIDs, 2)},
+		{"left", []SessionOption{WithAnchor("ds1")}, IntersectPlainLeft(tables, sourceIDs, "ds1")},
+		{"proofs", []SessionOption{WithHelperProofs()}, IntersectPlain(tables, sourceIDs)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rsk, rpk := KeyGen()
+			sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, test.opts...)
+			require.NoError(t, err, "NewSession() error")
+
+			dir := t.TempDir()
+			helper := NewHelper(sess)
+			receiver := NewReceiver(sess, rsk, WithOutOfCore(dir, 3))
+			require.NoError(t, receiver.SetHelperCommitments(helper.Commitments()))
+			ds := NewDataSource(sess, WithPadding(PadToSize(4)))
+
+			encTables := make(map[PartyID]EncTable, len(tables))
+			for sourceID, table := range tables {
+				prepTable, err := ds.Prepare(table)
+				require.NoError(t, err, "Prepare() error")
+				encTables[sourceID] = prepTable
+			}
+			joinedTables, err := helper.Convert(encTables)
+			require.NoError(t, err, "Convert() error")
+
+			intersection, err := receiver.JoinTables(joinedTables)
+			require.NoError(t, err, "JoinTables() error")
+			if !test.expected.EqualContents(&intersection) {
+				t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", test.expected, intersection)
+			}
+			requireEmptyDir(t, dir)
+
+			// the temporary files are removed on errors
+			tampered := slices.Clone(joinedTables)
+			for i := range tampered {
+				tampered[i].CVal = slices.Clone(tampered[i].CVal)
+				tampered[i].CVal[0] ^= 1
+			}
+			_, err = receiver.JoinTables(tampered)
+			var rowErr *RowError
+			if !errors.Is(err, ErrAuthentication) || !errors.As(err, &rowErr) {
+				t.Errorf("Expected an authentication error identifying a tampered row, got %v", err)
+			}
+			requireEmptyDir(t, dir)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = receiver.JoinTablesStream(ctx, make(chan EncRowWithHint))
+			require.ErrorIs(t, err, context.Canceled)
+			requireEmptyDir(t, dir)
+		})
+	}
+
+	t.Run("cardinality", func(t *testing.T) {
+		rsk, rpk := KeyGen()
+		sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithCardinalityOnly(), WithThreshold(2))
+		require.NoError(t, err, "NewSession() error")
+
+		dir := t.TempDir()
+		helper := NewHelper(sess)
+		receiver := NewReceiver(sess, rsk, WithOutOfCore(dir, 0))
+		ds := NewDataSource(sess)
+
+		encTables := make(map[PartyID]EncTable, len(tables))
+		for sourceID, table := range tables {
+			prepTable, err := ds.Prepare(table)
+			require.NoError(t, err, "Prepare() error")
+			encTables[sourceID] = prepTable
+		}
+		joinedTables, err := helper.Convert(encTables)
+		require.NoError(t, err, "Convert() error")
+
+		count, err := receiver.CountTables(joinedTables)
+		require.NoError(t, err, "CountTables() error")
+		require.Equal(t, 4, count)
+		requireEmptyDir(t, dir)
+	})
+}
+
+func requireEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "expected the temporary files to be removed")
+}
+
+func TestConvertStreamExternal(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := GenTestTables(sourceIDs, 20, 10)
+
+	rsk, rpk := KeyGen()
+	sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+	require.NoError(t, err, "NewSession() error")
+
+	dir := t.TempDir()
+	helper := NewHelper(sess, WithExternalShuffle(dir, 4))
+	receiver := NewReceiver(sess, rsk)
+	ds := NewDataSource(sess)
+
+	encTables := make(map[PartyID]EncTable, len(tables))
+	for sourceID, table := range tables {
+		prepTable, err := ds.Prepare(table)
+		require.NoError(t, err, "Prepare() error")
+		encTables[sourceID] = prepTable
+	}
+
+	ctx := context.Background()
+	convRows, errc := helper.ConvertStreamExternal(ctx, rpk, helper.feedTables(ctx, encTables), 2)
+	in := make(chan EncRowWithHint)
+	var intersection JoinTable
+	joinErr := make(chan error, 1)
+	go func() {
+		var err error
+		intersection, err = receiver.JoinTablesStream(ctx, in)
+		joinErr <- err
+	}()
+	count := 0
+	for row := range convRows {
+		in <- row
+		count++
+	}
+	close(in)
+	require.NoError(t, <-errc, "ConvertStreamExternal() error")
+	require.NoError(t, <-joinErr, "JoinTablesStream() error")
+	require.Equal(t, 60, count)
+	expected := IntersectPlain(tables, sourceIDs)
+	if !expected.EqualContents(&intersection) {
+		t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+	}
+	requireEmptyDir(t, dir)
+
+	// the temporary files are removed on errors
+	tasks := make(chan ConvertRowTask, 2)
+	tasks <- ConvertRowTask{EncRowMsg: encTables["ds1"][0], SourceID: "ds1"}
+	tasks <- ConvertRowTask{EncRowMsg: EncRow{Cuid: encTables["ds2"][0].Cuid}, SourceID: "ds2"}
+	close(tasks)
+	convRows, errc = helper.ConvertStreamExternal(ctx, rpk, tasks, 1)
+	for range convRows {
+	}
+	var rowErr *RowError
+	if err := <-errc; !errors.As(err, &rowErr) || rowErr.Row != 0 || rowErr.Source != "ds2" {
+		t.Errorf("Expected an error for row 0 of ds2, got %v", err)
+	}
+	requireEmptyDir(t, dir)
+}
+
+func TestPrepareRowStream(t *testing.T) {
+
+	sourceIDs := []PartyID{"ds1", "ds2", "ds3"}
+	tables := map[PartyID]TablePlain{
+		"ds1": {"a": "1a", "b": "1b", "c": "1c", " A": "1A"},
+		"ds2": {"a": "2a", "b": "2b", "d": "2d"},
+		"ds3": {"a": "3a", "c": "3c", "d": "3d", "e": "3e"},
+	}
+	csvTable := func(table TablePlain) *csv.Reader {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		for _, row := range table.Rows() {
+			require.NoError(t, w.Write(append([]string{row.UID}, row.Values...)))
+		}
+		w.Flush()
+		return csv.NewReader(&buf)
+	}
+	prepare := func(ds *DataSource, table TablePlain) EncTable {
+		encRows, errc := ds.PrepareRowStream(context.Background(), NewCSVRowReader(csvTable(table)), 1, 2)
+		var encTable EncTable
+		for encRow := range encRows {
+			encTable = append(encTable, encRow)
+		}
+		require.NoError(t, <-errc, "PrepareRowStream() error")
+		return encTable
+	}
+
+	t.Run("join", func(t *testing.T) {
+		rsk, rpk := KeyGen()
+		sess, err := NewSession(sourceIDs, "helper", "receiver", rpk)
+		require.NoError(t, err, "NewSession() error")
+
+		dir := t.TempDir()
+		helper := NewHelper(sess)
+		receiver := NewReceiver(sess, rsk)
+		ds := NewDataSource(sess, WithPadding(PadToSize(8)), WithShuffleBuckets(dir, 3))
+
+		encTables := make(map[PartyID]EncTable, len(tables))
+		for sourceID, table := range tables {
+			encTables[sourceID] = prepare(ds, table)
+			require.Len(t, encTables[sourceID], 8)
+			requireEmptyDir(t, dir)
+		}
+		joinedTables, err := helper.Convert(encTables)
+		require.NoError(t, err, "Convert() error")
+		intersection, err := receiver.JoinTables(joinedTables)
+		require.NoError(t, err, "JoinTables() error")
+		expected := IntersectPlain(tables, sourceIDs)
+		if !expected.EqualContents(&intersection) {
+			t.Errorf("Expected tables' contents to be equal, but they are not: \n Plain: \n%s \n MPPJ: \n%s", expected, intersection)
+		}
+
+		// the temporary files are removed on errors
+		table := "a,1a\nb,1b,extra\nc,1c\n"
+		encRows, errc := ds.PrepareRowStream(context.Background(), NewCSVRowReader(csv.NewReader(strings.NewReader(table))), 1)
+		for range encRows {
+		}
+		var rowErr *RowError
+		if err := <-errc; !errors.As(err, &rowErr) || rowErr.Row != 1 {
+			t.Errorf("Expected an error for row 1, got %v", err)
+		}
+		requireEmptyDir(t, dir)
+	})
+
+	t.Run("cardinality", func(t *testing.T) {
+		rsk, rpk := KeyGen()
+		sess, err := NewSession(sourceIDs, "helper", "receiver", rpk, WithCardinalityOnly(), WithNormalization(TrimSpace(), CaseFold()), WithThreshold(2))
+		require.NoError(t, err, "NewSession() error")
+
+		helper := NewHelper(sess)
+		receiver := NewReceiver(sess, rsk)
+		ds := NewDataSource(sess, WithPadding(PadToSize(8)), WithShuffleBuckets(t.TempDir(), 2))
+
+		encTables := make(map[PartyID]EncTable, len(tables))
+		for sourceID, table := range tables {
+			encTables[sourceID] = prepare(ds, table)
+			require.Len(t, encTables[sourceID], 8)
+			for _, row := range encTables[sourceID] {
+				require.Empty(t, row.Cval)
+			}
+		}
+		joinedTables, err := helper.Convert(encTables)
+		require.NoError(t, err, "Convert() error")
+		count, err := receiver.CountTables(joinedTables)
+		require.NoError(t, err, "CountTables() error")
+		require.Equal(t, 4, count)
+
+		// " A" is a duplicate of "a" after normalization
+		require.Len(t, prepare(NewDataSource(sess, WithShuffleBuckets(t.TempDir(), 2)), tables["ds1"]), 3)
+	})
+}
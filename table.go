@@ -2,8 +2,10 @@ package mppj
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/csv"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -13,16 +15,47 @@ import (
 // It is the protocol input type for data sources.
 type TablePlain map[string]string
 
-// TableRow represents a single row in a plain table.
-type TableRow struct {
-	uid string
-	val string
+// Row represents a single row in a plain table, with one value per column.
+type Row struct {
+	UID    string
+	Values []string
+
+	dummy bool
+}
+
+// MultiTablePlain represents a plain table with several named value columns per UID.
+// It is the protocol input type for data sources contributing multiple attributes, or
+// several rows per UID.
+type MultiTablePlain struct {
+	Columns []string
+	Rows    []Row
 }
 
-// EncRow represents a single encrypted row with encrypted UID and encrypted value(s).
+// EncRow represents a single encrypted row with encrypted UID and encrypted values, one per column.
 type EncRow struct {
-	Cuid *Ciphertext
-	Cval []*Ciphertext
+	Cuid Ciphertext
+	Cval []EncValue
+
+	// Proof is the source's proof of knowledge of the randomness of Cuid, in sessions with source proofs.
+	// It is nil otherwise.
+	Proof *SourceProof
+
+	session []byte // the tag of the session in which the row was prepared, see sessionTag
+}
+
+// sessionTagSize is the size in bytes of the session tags of the encrypted rows.
+const sessionTagSize = 8
+
+// sessionTag returns the tag of the rows prepared in the session sid, which the helper checks against its own
+// session. As the session IDs are derived with a KDF from the session parameters, see NewSessionWithID, the tag
+// is a prefix of the ID.
+func sessionTag(sid SessionID) []byte {
+	return sid[:min(len(sid), sessionTagSize)]
+}
+
+// hasUID returns whether the row carries a UID ciphertext, which is not the case for the zero EncRow.
+func (er EncRow) hasUID() bool {
+	return er.Cuid.c0.p != nil && er.Cuid.c1.p != nil
 }
 
 // EncTable represents an encrypted table as a slice of encrypted rows.
@@ -35,6 +68,25 @@ type EncRowWithHint struct {
 	CVal    SymmetricCiphertext
 	CValKey Ciphertext
 	CHint   Ciphertext
+
+	// Proof is the helper's proof of the consistency of CHint with its key commitments, in sessions with
+	// helper proofs. It is nil otherwise, and for the rows whose hint is not used, as in left joins.
+	Proof *HintProof
+
+	// KeyProof is the helper's proof of the consistency of CValKey with Cnyme and with its commitment to
+	// the pad key, in sessions with helper proofs. It is nil otherwise.
+	KeyProof *ValueKeyProof
+
+	// Origin is the index of the row's source when it is revealed to the receiver, which is the case
+	// for all the rows of threshold sessions and for the anchor rows of left join sessions, and -1 otherwise.
+	Origin int
+
+	pos int // position of the row in the receiver's input, for error reporting
+}
+
+// hasValues returns whether the row carries encrypted values, which is not the case in cardinality-only sessions.
+func (er EncRowWithHint) hasValues() bool {
+	return er.CValKey.c0.p != nil
 }
 
 // EncTableWithHint represents an encrypted table after processing by the helper.
@@ -42,10 +94,14 @@ type EncRowWithHint struct {
 type EncTableWithHint []EncRowWithHint
 
 // JoinTable represents the final joined table produced by the receiver.
-// It is the output type for the receiver.
+// It is the output type for the receiver. Its columns are qualified as source.column
+// for sources with named columns, and by the source ID alone otherwise. The cells of
+// sources without a row for the UID, as in threshold and left joins, are null.
 type JoinTable struct {
 	sourceids []PartyID
+	columns   [][]string
 	values    [][]string
+	nulls     [][]bool
 }
 
 // Len returns the number of rows in the joined table.
@@ -55,21 +111,173 @@ func (t JoinTable) Len() int {
 
 // MarshalBinary serializes an EncRow into a byte slice.
 func (er EncRow) MarshalBinary() ([]byte, error) {
-	var buf bytes.Buffer
-	cuidBytes, err := er.Cuid.Serialize()
+	if len(er.session) != sessionTagSize {
+		return nil, errMissingSession
+	}
+	buf, err := er.Cuid.appendBinary(append(make([]byte, 0, sessionTagSize+ciphertextSize+1), er.session...))
+	if err != nil {
+		return nil, err
+	}
+	if er.Proof == nil {
+		buf = append(buf, 0)
+	} else {
+		proofBytes, err := er.Proof.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, 1), proofBytes...)
+	}
+	cvalBytes, err := serializeEncValues(er.Cval)
 	if err != nil {
 		return nil, err
 	}
-	if len(er.Cval) > 1 {
-		return nil, fmt.Errorf("multiple ciphertext values not supported")
+	return append(buf, cvalBytes...), nil
+}
+
+// UnmarshalBinary deserializes a byte slice into an EncRow.
+func (er *EncRow) UnmarshalBinary(data []byte) error {
+	if len(data) < sessionTagSize+ciphertextSize+1 {
+		return fmt.Errorf("invalid byte slice length for deserialization of row")
+	}
+	session := bytes.Clone(data[:sessionTagSize])
+	data = data[sessionTagSize:]
+	var cuid Ciphertext
+	if err := cuid.deserialize(data[:ciphertextSize]); err != nil {
+		return err
+	}
+	data = data[ciphertextSize:]
+	var proof *SourceProof
+	switch hasProof := data[0]; {
+	case hasProof == 1 && len(data) >= 1+sourceProofSize:
+		proof = new(SourceProof)
+		if err := proof.UnmarshalBinary(data[1 : 1+sourceProofSize]); err != nil {
+			return err
+		}
+		data = data[1+sourceProofSize:]
+	case hasProof == 0:
+		data = data[1:]
+	default:
+		return fmt.Errorf("invalid proof encoding in row")
+	}
+	cval, err := deserializeEncValues(data)
+	if err != nil {
+		return err
 	}
-	cvalBytes, err := er.Cval[0].Serialize()
+	er.Cuid, er.Cval, er.Proof, er.session = cuid, cval, proof, session
+	return nil
+}
+
+// The flags of the proofs of a serialized EncRowWithHint.
+const (
+	hasHintProof     = 1 << iota // the row carries a HintProof
+	hasValueKeyProof             // the row carries a ValueKeyProof
+)
+
+// MarshalBinary serializes an EncRowWithHint into a byte slice. Rows without values are serialized
+// as the pseudonym and the origin only. Otherwise, the symmetric ciphertext of the values comes last,
+// ending with its TagSize-byte authentication tag.
+func (er EncRowWithHint) MarshalBinary() ([]byte, error) {
+	buf, err := er.Cnyme.appendBinary(make([]byte, 0, 3*ciphertextSize+originSize+1+2+hintBranchSize+valueKeyProofSize+len(er.CVal)))
 	if err != nil {
 		return nil, err
 	}
-	buf.Write(cuidBytes)
-	buf.Write(cvalBytes)
-	return buf.Bytes(), nil
+	if !er.hasValues() {
+		return binary.BigEndian.AppendUint16(buf, uint16(er.Origin)), nil
+	}
+	if buf, err = er.CValKey.appendBinary(buf); err != nil {
+		return nil, err
+	}
+	if buf, err = er.CHint.appendBinary(buf); err != nil {
+		return nil, err
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(er.Origin)) // hidden origins are encoded as 0xFFFF
+	var proofs byte                                             // a flag for each proof of the row
+	if er.Proof != nil {
+		proofs |= hasHintProof
+	}
+	if er.KeyProof != nil {
+		proofs |= hasValueKeyProof
+	}
+	buf = append(buf, proofs)
+	if er.Proof != nil {
+		proofBytes, err := er.Proof.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, proofBytes...)
+	}
+	if er.KeyProof != nil {
+		proofBytes, err := er.KeyProof.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, proofBytes...)
+	}
+	return append(buf, er.CVal...), nil
+}
+
+// UnmarshalBinary deserializes a byte slice into an EncRowWithHint.
+func (er *EncRowWithHint) UnmarshalBinary(data []byte) error {
+	if len(data) == ciphertextSize+originSize { // row without values
+		var cnyme Ciphertext
+		if err := cnyme.deserialize(data[:ciphertextSize]); err != nil {
+			return err
+		}
+		*er = EncRowWithHint{Cnyme: cnyme, Origin: int(binary.BigEndian.Uint16(data[ciphertextSize:]))}
+		if er.Origin == MaxSources {
+			er.Origin = hiddenOrigin
+		}
+		return nil
+	}
+	if len(data) < 3*ciphertextSize+originSize+1 {
+		return fmt.Errorf("invalid byte slice length for deserialization of row")
+	}
+	var cts [3]Ciphertext // the elements of er may be shared with copies of er
+	for i := range cts {
+		if err := cts[i].deserialize(data[i*ciphertextSize : (i+1)*ciphertextSize]); err != nil {
+			return err
+		}
+	}
+	data = data[3*ciphertextSize:]
+	er.Cnyme, er.CValKey, er.CHint = cts[0], cts[1], cts[2]
+	er.Origin = int(binary.BigEndian.Uint16(data))
+	if er.Origin == MaxSources {
+		er.Origin = hiddenOrigin
+	}
+	data = data[originSize:]
+	proofs := data[0]
+	if proofs&^(hasHintProof|hasValueKeyProof) != 0 {
+		return fmt.Errorf("invalid proof encoding in row")
+	}
+	data = data[1:]
+	er.Proof, er.KeyProof = nil, nil
+	if proofs&hasHintProof != 0 {
+		n := hintProofLen(data)
+		if n < 0 {
+			return fmt.Errorf("invalid byte slice length for deserialization of row")
+		}
+		er.Proof = new(HintProof)
+		if err := er.Proof.UnmarshalBinary(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	if proofs&hasValueKeyProof != 0 {
+		if len(data) < valueKeyProofSize {
+			return fmt.Errorf("invalid byte slice length for deserialization of row")
+		}
+		er.KeyProof = new(ValueKeyProof)
+		if err := er.KeyProof.UnmarshalBinary(data[:valueKeyProofSize]); err != nil {
+			return err
+		}
+		data = data[valueKeyProofSize:]
+	}
+	if len(data) < TagSize {
+		return fmt.Errorf("invalid byte slice length for deserialization of row")
+	}
+	er.CVal = make(SymmetricCiphertext, len(data))
+	copy(er.CVal, data)
+	return nil
 }
 
 // NewTablePlain creates a new Table from a UID list and optional values.
@@ -85,38 +293,168 @@ func NewTablePlain(uids []string, values []string) TablePlain {
 	return TablePlain(newTable)
 }
 
-// NewJoinTable creates a new empty JoinTable for the given source IDs.
+// Rows returns the rows of the plain table, as single-column rows in UID order.
+func (t TablePlain) Rows() []Row {
+	rows := make([]Row, 0, len(t))
+	for _, uid := range slices.Sorted(maps.Keys(t)) {
+		rows = append(rows, Row{UID: uid, Values: []string{t[uid]}})
+	}
+	return rows
+}
+
+// RowReader is an iterator over the rows of a plain table, for tables that are not held in memory. ReadRow
+// returns io.EOF after the last row.
+type RowReader interface {
+	ReadRow() (Row, error)
+}
+
+type csvRowReader struct {
+	r *csv.Reader
+}
+
+// NewCSVRowReader returns a RowReader over the records of r, where the first field of each record is the UID
+// and the other fields are the values.
+func NewCSVRowReader(r *csv.Reader) RowReader {
+	return csvRowReader{r: r}
+}
+
+func (c csvRowReader) ReadRow() (Row, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return Row{}, err
+	}
+	if len(record) == 0 {
+		return Row{}, fmt.Errorf("empty CSV record")
+	}
+	return Row{UID: record[0], Values: record[1:]}, nil
+}
+
+// Validate checks that the rows of the table match its columns.
+func (t MultiTablePlain) Validate() error {
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("table has no columns")
+	}
+	for i, row := range t.Rows {
+		if len(row.Values) != len(t.Columns) {
+			return fmt.Errorf("row %d has %d values, expected %d", i, len(row.Values), len(t.Columns))
+		}
+	}
+	return nil
+}
+
+// NewJoinTable creates a new empty JoinTable for the given source IDs, with one value column per source.
 func NewJoinTable(sourceIDs []PartyID) JoinTable {
+	return NewJoinTableWithColumns(sourceIDs, nil)
+}
+
+// NewJoinTableWithColumns creates a new empty JoinTable for the given source IDs, where the sources in columns
+// contribute the named value columns, and the other sources a single unnamed value column.
+func NewJoinTableWithColumns(sourceIDs []PartyID, columns map[PartyID][]string) JoinTable {
 
 	var newTable = JoinTable{
 		sourceids: make([]PartyID, len(sourceIDs)),
+		columns:   make([][]string, len(sourceIDs)),
 		values:    make([][]string, 0),
+		nulls:     make([][]bool, 0),
 	}
 	copy(newTable.sourceids, sourceIDs)
+	for i, sourceID := range sourceIDs {
+		newTable.columns[i] = slices.Clone(columns[sourceID])
+	}
 	return newTable
 }
 
+// Columns returns the qualified column names of the joined table.
+func (t JoinTable) Columns() []string {
+	cols := make([]string, 0, len(t.sourceids))
+	for i, sid := range t.sourceids {
+		if len(t.columns[i]) == 0 {
+			cols = append(cols, string(sid))
+			continue
+		}
+		for _, col := range t.columns[i] {
+			cols = append(cols, string(sid)+"."+col)
+		}
+	}
+	return cols
+}
+
+// numColumns returns the number of value columns of source at index i.
+func (t JoinTable) numColumns(i int) int {
+	return max(len(t.columns[i]), 1)
+}
+
 // Insert adds a new row to the joined table with the given values mapped by source ID.
+// It requires all the given sources to contribute a single value column.
 func (t *JoinTable) Insert(values map[PartyID]string) error {
-	row := make([]string, len(t.sourceids))
+	rowValues := make(map[PartyID][]string, len(values))
 	for sourceID, value := range values {
+		rowValues[sourceID] = []string{value}
+	}
+	return t.InsertRow(rowValues)
+}
+
+// InsertRow adds a new row to the joined table with the given column values mapped by source ID.
+// The columns of the sources absent from values are null.
+func (t *JoinTable) InsertRow(values map[PartyID][]string) error {
+	offsets := make([]int, len(t.sourceids)+1)
+	for i := range t.sourceids {
+		offsets[i+1] = offsets[i] + t.numColumns(i)
+	}
+	row := make([]string, offsets[len(t.sourceids)])
+	nulls := make([]bool, len(row))
+	for i := range nulls {
+		nulls[i] = true
+	}
+	for sourceID, vals := range values {
 		col := slices.Index(t.sourceids, sourceID)
 		if col == -1 {
 			return fmt.Errorf("source ID %s not found", sourceID)
 		}
-		row[col] = value
+		if len(vals) != t.numColumns(col) {
+			return fmt.Errorf("source ID %s has %d values, expected %d", sourceID, len(vals), t.numColumns(col))
+		}
+		copy(row[offsets[col]:], vals)
+		for i := range vals {
+			nulls[offsets[col]+i] = false
+		}
 	}
 	t.values = append(t.values, row)
+	t.nulls = append(t.nulls, nulls)
 	return nil
 }
 
-// WriteTo writes the joined table to a CSV writer.
-func (t JoinTable) WriteTo(w *csv.Writer) error {
-	sourceIDsStr := make([]string, len(t.sourceids))
-	for i, sid := range t.sourceids {
-		sourceIDsStr[i] = string(sid)
+// InsertProduct adds the cross product of the given rows to the joined table. The rows are given as
+// a list of column values per source ID, and the columns of the sources absent from values are null.
+func (t *JoinTable) InsertProduct(values map[PartyID][][]string) error {
+	rows := []map[PartyID][]string{make(map[PartyID][]string, len(values))}
+	for sourceID, sourceRows := range values {
+		product := make([]map[PartyID][]string, 0, len(rows)*len(sourceRows))
+		for _, row := range rows {
+			for _, vals := range sourceRows {
+				newRow := maps.Clone(row)
+				newRow[sourceID] = vals
+				product = append(product, newRow)
+			}
+		}
+		rows = product
+	}
+	for _, row := range rows {
+		if err := t.InsertRow(row); err != nil {
+			return err
+		}
 	}
-	if err := w.Write(sourceIDsStr); err != nil {
+	return nil
+}
+
+// Cell returns the value at row i and column j of the joined table, and false if the value is null.
+func (t JoinTable) Cell(i, j int) (string, bool) {
+	return t.values[i][j], !t.nulls[i][j]
+}
+
+// WriteTo writes the joined table to a CSV writer. Null values are written as empty fields.
+func (t JoinTable) WriteTo(w *csv.Writer) error {
+	if err := w.Write(t.Columns()); err != nil {
 		return err
 	}
 	for _, row := range t.values {
@@ -159,37 +497,54 @@ func (t1 *TablePlain) Equal(t2 *TablePlain) bool {
 	return true
 }
 
-// EqualContents checks only the contents of the joined tables, ignoring the order of rows.
+// EqualContents checks only the contents of the joined tables, ignoring the order of rows. The rows are
+// compared as multisets, so that both tables must have each row the same number of times.
 func (t1 *JoinTable) EqualContents(t2 *JoinTable) bool {
 
 	if t1.Len() != t2.Len() {
 		return false
 	}
 
-	for sid := range t1.sourceids {
-		if t1.sourceids[sid] != t2.sourceids[sid] {
-			return false
-		}
+	if !slices.Equal(t1.Columns(), t2.Columns()) {
+		return false
 	}
 
-	t1Vals := make(map[string]struct{})
-	for _, row := range t1.values {
-		rowKey := strings.Join(row, "|") // TODO: more robust way to determine equality
-		t1Vals[rowKey] = struct{}{}
+	t1Vals := make(map[string]int)
+	for i := range t1.values {
+		t1Vals[t1.rowKey(i)]++
 	}
 
-	for _, row := range t2.values {
-		rowKey := strings.Join(row, "|")
-		if _, exists := t1Vals[rowKey]; !exists {
+	for i := range t2.values {
+		key := t2.rowKey(i)
+		if t1Vals[key] == 0 {
 			return false
 		}
+		t1Vals[key]--
 	}
 
 	return true
 }
 
+// rowKey returns a string representation of row i, for comparing rows.
+func (t *JoinTable) rowKey(i int) string {
+	cells := make([]string, len(t.values[i]))
+	for j, val := range t.values[i] {
+		if t.nulls[i][j] {
+			val = "\x00null"
+		}
+		cells[j] = val
+	}
+	return strings.Join(cells, "|") // TODO: more robust way to determine equality
+}
+
 // IntersectPlain performs a join on plain tables
 func IntersectPlain(tables map[PartyID]TablePlain, sources []PartyID) JoinTable {
+	return IntersectPlainThreshold(tables, sources, len(tables))
+}
+
+// IntersectPlainThreshold performs a threshold join on plain tables, keeping the UIDs present in at least k
+// tables. The values of the tables missing a UID are left blank.
+func IntersectPlainThreshold(tables map[PartyID]TablePlain, sources []PartyID, k int) JoinTable {
 
 	// groups the values by uids
 	partJoin := make(map[string]map[PartyID]string)
@@ -204,13 +559,79 @@ func IntersectPlain(tables map[PartyID]TablePlain, sources []PartyID) JoinTable
 
 	joined := NewJoinTable(sources)
 	for _, vals := range partJoin {
-		if len(vals) == len(tables) {
+		if len(vals) >= k {
 			joined.Insert(vals)
 		}
 	}
 	return joined
 }
 
+// IntersectPlainLeft performs a left join on plain tables, anchored on the anchor source. Every row of the
+// anchor table is in the join, and the values of the tables missing its UID are null.
+func IntersectPlainLeft(tables map[PartyID]TablePlain, sources []PartyID, anchor PartyID) JoinTable {
+
+	joined := NewJoinTable(sources)
+	for uid, anchorVal := range tables[anchor] {
+		vals := map[PartyID]string{anchor: anchorVal}
+		for sourceID, table := range tables {
+			if val, exists := table[uid]; exists {
+				vals[sourceID] = val
+			}
+		}
+		joined.Insert(vals)
+	}
+	return joined
+}
+
+// IntersectPlainMulti performs a join on plain multi-column tables. The UIDs with several rows in
+// a table are joined as the cross product of their rows.
+func IntersectPlainMulti(tables map[PartyID]MultiTablePlain, sources []PartyID) JoinTable {
+	return IntersectPlainMultiThreshold(tables, sources, len(tables))
+}
+
+// IntersectPlainMultiThreshold performs a threshold join on plain multi-column tables, keeping the UIDs present
+// in at least k tables as the cross product of their rows. The columns of the tables missing a UID are null.
+func IntersectPlainMultiThreshold(tables map[PartyID]MultiTablePlain, sources []PartyID, k int) JoinTable {
+	joined, partJoin := groupPlainMulti(tables, sources)
+	for _, vals := range partJoin {
+		if len(vals) >= k {
+			joined.InsertProduct(vals)
+		}
+	}
+	return joined
+}
+
+// IntersectPlainMultiLeft performs a left join on plain multi-column tables, anchored on the anchor source.
+// Every row of the anchor table is in the join, once per combination of the rows of the other tables with
+// its UID.
+func IntersectPlainMultiLeft(tables map[PartyID]MultiTablePlain, sources []PartyID, anchor PartyID) JoinTable {
+	joined, partJoin := groupPlainMulti(tables, sources)
+	for _, vals := range partJoin {
+		if _, exists := vals[anchor]; exists {
+			joined.InsertProduct(vals)
+		}
+	}
+	return joined
+}
+
+// groupPlainMulti returns an empty joined table with the columns of the tables, and their rows grouped by UID
+// and by source.
+func groupPlainMulti(tables map[PartyID]MultiTablePlain, sources []PartyID) (JoinTable, map[string]map[PartyID][][]string) {
+
+	columns := make(map[PartyID][]string, len(tables))
+	partJoin := make(map[string]map[PartyID][][]string)
+	for sourceID, table := range tables {
+		columns[sourceID] = table.Columns
+		for _, row := range table.Rows {
+			if _, exists := partJoin[row.UID]; !exists {
+				partJoin[row.UID] = make(map[PartyID][][]string)
+			}
+			partJoin[row.UID][sourceID] = append(partJoin[row.UID][sourceID], row.Values)
+		}
+	}
+	return NewJoinTableWithColumns(sources, columns), partJoin
+}
+
 // String returns the plain table as a formatted string.
 func (t TablePlain) String() string {
 	var s string
@@ -2,9 +2,13 @@ package mppj
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/hkdf"
 	"crypto/sha256"
 	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
@@ -13,19 +17,57 @@ import (
 
 type PartyID string
 
+// MaxSources is the maximum number of sources in a session, as the origin of each row is encoded on
+// originSize bytes, with one value reserved for hidden origins.
+const MaxSources = 1<<(8*originSize) - 1
+
+const originSize = 2
+
+// hiddenOrigin is the origin of the rows whose source is not revealed to the receiver.
+const hiddenOrigin = -1
+
 type SessionID []byte
 
+// RowError is the error returned by the streaming methods when the processing of a row fails. It identifies
+// the row by its position in the input of the party, and by its source when the source is known to the party.
+type RowError struct {
+	Source PartyID // empty if the source of the row is unknown to the party
+	Row    int
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d of source %s: %v", e.Row, e.Source, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// numWorkers returns the number of goroutines workers of the streaming methods, from their optional argument,
+// which defaults to the number of CPUs.
+func numWorkers(goroutines []int) int {
+	if len(goroutines) > 0 && goroutines[0] > 0 {
+		return goroutines[0]
+	}
+	return runtime.NumCPU()
+}
+
 // NewSessionID generates a new session ID based on session participants and randomness.
 func NewSessionID(sources []PartyID, helper, receiver string) SessionID {
 
 	sidprime := uuid.New().String()
 
-	info := fmt.Sprintf("%d", len(sources)) + "|" + helper + "|" + receiver
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("%d", len(sources)) + "|" + helper + "|" + receiver)
 	for _, ds := range sources {
-		info += "|" + string(ds)
+		info.WriteString("|" + string(ds))
 	}
 
-	sid, err := hkdf.Key(sha256.New, []byte(sidprime), nil, info, sha256.New().Size())
+	sid, err := hkdf.Key(sha256.New, []byte(sidprime), nil, info.String(), sha256.New().Size())
 	if err != nil {
 		panic(err)
 	}
@@ -35,43 +77,302 @@ func NewSessionID(sources []PartyID, helper, receiver string) SessionID {
 
 // Session represents the public parameters of an MPPJ session.
 type Session struct {
+	// ID is the session ID, derived by NewSessionWithID from the given ID and from all the parameters below.
 	ID         SessionID
 	Sources    []PartyID
 	Helper     PartyID
 	Receiver   PartyID
 	ReceiverPK PublicKey
+
+	// Columns maps sources to the names of their value columns. Sources without an entry
+	// contribute a single unnamed value column.
+	Columns map[PartyID][]string
+
+	// Threshold is the minimum number of sources a UID must be present in for its row to be
+	// part of the join. Zero means that the UID must be present in all sources.
+	Threshold int
+
+	// Anchor is the source on which the join is anchored in left join sessions, and empty otherwise.
+	Anchor PartyID
+
+	// KeySchema holds the names of the identifier columns of composite join keys, and is empty
+	// if the sources join on single-string UIDs.
+	KeySchema []string
+
+	// Normalization is the chain of normalizers applied by the sources to the join keys before hashing.
+	Normalization []Normalizer
+
+	// CardinalityOnly indicates that the receiver only learns the size of the join, and not its values.
+	CardinalityOnly bool
+
+	// HelperProofs indicates that the helper proves the consistency of the hints with its key commitments.
+	HelperProofs bool
+
+	// SourceProofs indicates that the sources prove the knowledge of the randomness of their UID ciphertexts.
+	SourceProofs bool
+
+	// Suite is the prime-order group of the session, which must be the suite the parties are built with, see
+	// [CompiledSuite].
+	Suite Suite
+
+	// SourceKeys maps the sources to their long-term Ed25519 public keys, with which the helper verifies the
+	// signatures of their uploads. It is empty if the uploads are not signed.
+	SourceKeys map[PartyID]ed25519.PublicKey
+}
+
+// SessionOption is an optional parameter of a Session.
+type SessionOption func(*Session) error
+
+// WithColumns declares the names of the value columns contributed by source.
+func WithColumns(source PartyID, columns ...string) SessionOption {
+	return func(s *Session) error {
+		if !slices.Contains(s.Sources, source) {
+			return fmt.Errorf("unknown source %s", source)
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("at least one column required for source %s", source)
+		}
+		if s.Columns == nil {
+			s.Columns = make(map[PartyID][]string)
+		}
+		s.Columns[source] = slices.Clone(columns)
+		return nil
+	}
+}
+
+// WithThreshold makes the session a threshold join, in which the receiver obtains the rows whose UID
+// is present in at least k sources, with blank columns for the missing sources. In this mode, the
+// receiver learns the source index of every row, including the rows that are not part of the join.
+func WithThreshold(k int) SessionOption {
+	return func(s *Session) error {
+		if k < 1 || k > len(s.Sources) {
+			return fmt.Errorf("threshold must be between 1 and %d, got %d", len(s.Sources), k)
+		}
+		s.Threshold = k
+		return nil
+	}
+}
+
+// WithAnchor makes the session a left join anchored on source: the receiver obtains every row of the
+// anchor source, together with the values of the other sources where their UID matches, and nulls
+// otherwise. In this mode, the receiver learns which rows originate from the anchor source.
+func WithAnchor(source PartyID) SessionOption {
+	return func(s *Session) error {
+		if !slices.Contains(s.Sources, source) {
+			return fmt.Errorf("unknown anchor source %s", source)
+		}
+		s.Anchor = source
+		return nil
+	}
+}
+
+// WithKeySchema declares that the sources join on composite keys made of the given identifier columns.
+// The sources must then use the UIDs obtained from [CompositeKey.UID] with as many parts as columns. The schema
+// is bound into the session ID, so that parties configured with different schemas end up in different sessions.
+func WithKeySchema(columns ...string) SessionOption {
+	return func(s *Session) error {
+		if len(columns) == 0 {
+			return fmt.Errorf("at least one key column required")
+		}
+		s.KeySchema = slices.Clone(columns)
+		return nil
+	}
+}
+
+// WithNormalization declares the normalization chain applied by every source to its join keys, in order.
+// For composite keys, the chain is applied to each part of the key. The digest of the chain is bound into
+// the session ID, so that parties configured with different chains end up in different sessions.
+func WithNormalization(rules ...Normalizer) SessionOption {
+	return func(s *Session) error {
+		if len(rules) == 0 {
+			return fmt.Errorf("at least one normalizer required")
+		}
+		s.Normalization = slices.Clone(rules)
+		return nil
+	}
+}
+
+// WithCardinalityOnly makes the session compute only the size of the join: the sources contribute their
+// UIDs without values, and the receiver counts the matching UIDs with [Receiver.CountTables].
+func WithCardinalityOnly() SessionOption {
+	return func(s *Session) error {
+		s.CardinalityOnly = true
+		return nil
+	}
 }
 
-// NewSessionWithID creates a new Session with the given ID and public parameters.
-func NewSessionWithID(sid SessionID, sources []PartyID, helper, receiver PartyID, receiverPK PublicKey) (*Session, error) {
+// WithHelperProofs makes the helper attach to each row proofs that its hint and its value key were computed
+// from its pseudonym with the keys committed to in [Helper.Commitments]. The hints of the rows with hidden
+// origins are proven without revealing their source, see [HintProof]. The receiver verifies the proofs, and
+// rejects inconsistent rows.
+func WithHelperProofs() SessionOption {
+	return func(s *Session) error {
+		s.HelperProofs = true
+		return nil
+	}
+}
+
+// WithSourceProofs makes the sources attach to each row a proof of knowledge of the randomness of its UID
+// ciphertext, bound to the session and to the source's PartyID. The helper verifies the proofs, and rejects
+// the rows that fail, such as copies of the rows of other sources. The sources then need to know their ID,
+// see [WithSourceID].
+func WithSourceProofs() SessionOption {
+	return func(s *Session) error {
+		s.SourceProofs = true
+		return nil
+	}
+}
+
+// WithSourceKey registers the long-term Ed25519 public key of source. The sources then sign their uploads, see
+// [DataSource.Sign], and the helper only converts the rows whose signature verifies, see [Helper.AcceptRows].
+// A key must be registered for every source of the session.
+func WithSourceKey(source PartyID, pk ed25519.PublicKey) SessionOption {
+	return func(s *Session) error {
+		if !slices.Contains(s.Sources, source) {
+			return fmt.Errorf("unknown source %s", source)
+		}
+		if len(pk) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key for source %s", source)
+		}
+		if s.SourceKeys == nil {
+			s.SourceKeys = make(map[PartyID]ed25519.PublicKey)
+		}
+		s.SourceKeys[source] = slices.Clone(pk)
+		return nil
+	}
+}
+
+// WithSuite declares the prime-order group of the session. Sessions declaring a suite other than the one the
+// package is built with are rejected.
+func WithSuite(suite Suite) SessionOption {
+	return func(s *Session) error {
+		s.Suite = suite
+		return nil
+	}
+}
+
+// NewSessionWithID creates a new Session with the given ID and public parameters. The ID of the session is
+// derived from the given ID and from the parameters, see [Session.ID].
+func NewSessionWithID(sid SessionID, sources []PartyID, helper, receiver PartyID, receiverPK PublicKey, opts ...SessionOption) (*Session, error) {
 	if len(sources) < 2 {
 		return nil, fmt.Errorf("at least two sources required")
 	}
+	if len(sources) > MaxSources {
+		return nil, fmt.Errorf("at most %d sources supported, got %d", MaxSources, len(sources))
+	}
+	seen := make(map[PartyID]struct{}, len(sources))
+	for _, source := range sources {
+		if _, exists := seen[source]; exists {
+			return nil, fmt.Errorf("duplicate source %s", source)
+		}
+		seen[source] = struct{}{}
+	}
 	if strings.EqualFold(string(helper), string(receiver)) {
 		return nil, fmt.Errorf("helper and receiver must be different")
 	}
-	return &Session{
+	sess := &Session{
 		ID:         sid,
 		Sources:    sources,
 		Helper:     helper,
 		Receiver:   receiver,
 		ReceiverPK: receiverPK,
-	}, nil
+		Suite:      suite,
+	}
+	for _, opt := range opts {
+		if err := opt(sess); err != nil {
+			return nil, err
+		}
+	}
+	if sess.Threshold > 0 && sess.Anchor != "" {
+		return nil, fmt.Errorf("threshold and left joins cannot be combined")
+	}
+	if sess.CardinalityOnly && (sess.Anchor != "" || len(sess.Columns) > 0) {
+		return nil, fmt.Errorf("cardinality-only sessions cannot have an anchor or value columns")
+	}
+	if sess.CardinalityOnly && sess.HelperProofs {
+		return nil, fmt.Errorf("cardinality-only sessions have no hints to prove")
+	}
+	if len(sess.SourceKeys) > 0 && len(sess.SourceKeys) != len(sess.Sources) {
+		return nil, fmt.Errorf("signing keys registered for %d sources out of %d", len(sess.SourceKeys), len(sess.Sources))
+	}
+	if sess.Suite != suite {
+		return nil, fmt.Errorf("session uses suite %s, but the package is built with suite %s", sess.Suite, suite)
+	}
+	id, err := hkdf.Key(sha256.New, sess.ID, sess.parameters(), "session-parameters", sha256.New().Size())
+	if err != nil {
+		return nil, err
+	}
+	sess.ID = id
+	sess.ReceiverPK.precompute()
+	return sess, nil
+}
+
+// parameters returns the canonical encoding of the parameters of the session which affect the protocol, which
+// NewSessionWithID binds into the session ID. Each parameter is encoded as a part of a CompositeKey, so that
+// parties configured with different parameters end up in different sessions.
+func (s *Session) parameters() []byte {
+	sources := make(CompositeKey, len(s.Sources))
+	columns := make(CompositeKey, len(s.Sources))
+	sourceKeys := make(CompositeKey, len(s.Sources))
+	for i, source := range s.Sources {
+		sources[i] = string(source)
+		columns[i] = string(CompositeKey(s.Columns[source]).Encode())
+		sourceKeys[i] = string(s.SourceKeys[source])
+	}
+	var receiverPK CompositeKey
+	for _, pk := range []*publicKey{s.ReceiverPK.bpk, s.ReceiverPK.epk} {
+		if pk != nil {
+			pb, _ := pk.p.MarshalBinary()
+			receiverPK = append(receiverPK, string(pb))
+		}
+	}
+
+	return CompositeKey{
+		string(s.Suite),
+		string(sources.Encode()),
+		string(s.Helper),
+		string(s.Receiver),
+		string(receiverPK.Encode()),
+		string(columns.Encode()),
+		strconv.Itoa(s.Threshold),
+		string(s.Anchor),
+		string(CompositeKey(s.KeySchema).Encode()),
+		string(NormalizationDigest(s.Normalization)),
+		strconv.FormatBool(s.CardinalityOnly),
+		strconv.FormatBool(s.HelperProofs),
+		strconv.FormatBool(s.SourceProofs),
+		string(sourceKeys.Encode()),
+	}.Encode()
 }
 
 // NewSession creates a new Session, generating the session ID from the given public parameters.
-func NewSession(sources []PartyID, helper, receiver PartyID, receiverPK PublicKey) (*Session, error) {
-	return NewSessionWithID(NewSessionID(sources, string(helper), string(receiver)), sources, helper, receiver, receiverPK)
+func NewSession(sources []PartyID, helper, receiver PartyID, receiverPK PublicKey, opts ...SessionOption) (*Session, error) {
+	return NewSessionWithID(NewSessionID(sources, string(helper), string(receiver)), sources, helper, receiver, receiverPK, opts...)
+}
+
+// NumColumns returns the number of value columns contributed by source.
+func (s *Session) NumColumns(source PartyID) int {
+	if s.CardinalityOnly {
+		return 0
+	}
+	if cols, ok := s.Columns[source]; ok {
+		return len(cols)
+	}
+	return 1
 }
 
 type contextKey string
 
 const sourceIDContextKey = contextKey("source-id")
 
+const sessionIDContextKey = contextKey("session-id-bin")
+
 func SourceIDToOutgoingContext(ctx context.Context, id PartyID) context.Context {
-	return metadata.AppendToOutgoingContext(context.Background(), string(sourceIDContextKey), string(id))
+	return metadata.AppendToOutgoingContext(ctx, string(sourceIDContextKey), string(id))
 }
 
+// SourceIDFromIncomingContext returns the source ID claimed in the incoming context. As any client can set it,
+// it is not authenticated, see [Helper.VerifyRows] for sessions with signed uploads.
 func SourceIDFromIncomingContext(ctx context.Context) (PartyID, bool) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -84,6 +385,24 @@ func SourceIDFromIncomingContext(ctx context.Context) (PartyID, bool) {
 	return PartyID(id[0]), true
 }
 
+// SessionIDToOutgoingContext attaches the session ID to the outgoing context, so that the receiving party
+// can check it with [Helper.CheckSessionID].
+func SessionIDToOutgoingContext(ctx context.Context, sid SessionID) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, string(sessionIDContextKey), string(sid))
+}
+
+func SessionIDFromIncomingContext(ctx context.Context) (SessionID, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	sid := md.Get(string(sessionIDContextKey))
+	if len(sid) == 0 {
+		return nil, false
+	}
+	return SessionID(sid[0]), true
+}
+
 type SourceList []PartyID
 
 func (s *SourceList) String() string {